@@ -60,23 +60,9 @@ func main() {
 	electionID := p4_v1.Uint128{High: 0, Low: 1}
 
 	p4RtC := client.NewClient(c, deviceID, electionID)
-	arbitrationCh := make(chan bool)
-	go p4RtC.Run(stopCh, arbitrationCh, nil)
-
-	waitCh := make(chan struct{})
-
 	go func() {
-		sent := false
-		for isPrimary := range arbitrationCh {
-			if isPrimary {
-				log.Infof("We are the primary client!")
-				if !sent {
-					waitCh <- struct{}{}
-					sent = true
-				}
-			} else {
-				log.Infof("We are not the primary client!")
-			}
+		if err := p4RtC.Run(stopCh, nil, nil); err != nil {
+			log.Errorf("Stream channel failed: %v", err)
 		}
 	}()
 
@@ -84,11 +70,10 @@ func main() {
 		timeout := 5 * time.Second
 		ctx, cancel := context.WithTimeout(ctx, timeout)
 		defer cancel()
-		select {
-		case <-ctx.Done():
+		if err := p4RtC.WaitForPrimary(ctx); err != nil {
 			log.Fatalf("Could not become the primary client within %v", timeout)
-		case <-waitCh:
 		}
+		log.Infof("We are the primary client!")
 	}()
 
 	log.Info("Setting forwarding pipe")
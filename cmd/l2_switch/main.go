@@ -85,10 +85,23 @@ func cleanup(ctx context.Context, p4RtC *client.Client) error {
 }
 
 func learnMacs(ctx context.Context, p4RtC *client.Client, digestList *p4_v1.DigestList) error {
-	for _, digestData := range digestList.Data {
-		s := digestData.GetStruct()
-		srcAddr := s.Members[0].GetBitstring()
-		ingressPort := s.Members[1].GetBitstring()
+	digests, err := p4RtC.DecodeDigestList(digestList)
+	if err != nil {
+		return fmt.Errorf("Cannot decode digest list: %v", err)
+	}
+	for _, digest := range digests {
+		s, ok := digest.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("Unexpected digest type %T", digest)
+		}
+		srcAddr, ok := s["srcAddr"].([]byte)
+		if !ok {
+			return fmt.Errorf("Missing srcAddr in digest: %v", s)
+		}
+		ingressPort, ok := s["ingressPort"].([]byte)
+		if !ok {
+			return fmt.Errorf("Missing ingressPort in digest: %v", s)
+		}
 		log.WithFields(log.Fields{
 			"srcAddr":     srcAddr,
 			"ingressPort": ingressPort,
@@ -108,8 +121,9 @@ func learnMacs(ctx context.Context, p4RtC *client.Client, digestList *p4_v1.Dige
 			p4RtC.NewTableActionDirect("NoAction", nil),
 			smacOptions,
 		)
-		if err := p4RtC.InsertTableEntry(ctx, smacEntry); err != nil {
-			log.Errorf("Cannot insert entry in 'smac': %v", err)
+		// the entries may already exist if the list is sent again after a failure
+		if err := p4RtC.InsertTableEntry(ctx, smacEntry); err != nil && !client.IsAlreadyExists(err) {
+			return fmt.Errorf("Cannot insert entry in 'smac': %v", err)
 		}
 
 		dmacEntry := p4RtC.NewTableEntry(
@@ -122,15 +136,11 @@ func learnMacs(ctx context.Context, p4RtC *client.Client, digestList *p4_v1.Dige
 			p4RtC.NewTableActionDirect("IngressImpl.fwd", [][]byte{ingressPort}),
 			nil,
 		)
-		if err := p4RtC.InsertTableEntry(ctx, dmacEntry); err != nil {
-			log.Errorf("Cannot insert entry in 'dmac': %v", err)
+		if err := p4RtC.InsertTableEntry(ctx, dmacEntry); err != nil && !client.IsAlreadyExists(err) {
+			return fmt.Errorf("Cannot insert entry in 'dmac': %v", err)
 		}
 	}
 
-	if err := p4RtC.AckDigestList(ctx, digestList); err != nil {
-		return fmt.Errorf("Error when acking digest list: %v", err)
-	}
-
 	return nil
 }
 
@@ -164,53 +174,66 @@ func forgetEntries(ctx context.Context, p4RtC *client.Client, notification *p4_v
 	}
 }
 
-func handleStreamMessages(ctx context.Context, p4RtC *client.Client, messageCh <-chan *p4_v1.StreamMessageResponse) {
-	for message := range messageCh {
-		switch m := message.Update.(type) {
-		case *p4_v1.StreamMessageResponse_Packet:
-			log.Debugf("Received PacketIn")
-		case *p4_v1.StreamMessageResponse_Digest:
-			log.Debugf("Received DigestList")
-			if err := learnMacs(ctx, p4RtC, m.Digest); err != nil {
-				log.Errorf("Error when learning MACs: %v", err)
-			}
-		case *p4_v1.StreamMessageResponse_IdleTimeoutNotification:
-			log.Debugf("Received IdleTimeoutNotification")
-			forgetEntries(ctx, p4RtC, m.IdleTimeoutNotification)
-		case *p4_v1.StreamMessageResponse_Error:
-			log.Errorf("Received StreamError")
-		default:
-			log.Errorf("Received unknown stream message")
+func registerStreamHandlers(ctx context.Context, p4RtC *client.Client) {
+	d := p4RtC.Dispatcher()
+	d.OnPacketIn(func(packetIn *client.PacketIn) {
+		log.WithFields(log.Fields{
+			"length":   len(packetIn.Payload),
+			"metadata": packetIn.Metadata,
+		}).Debugf("Received PacketIn")
+	})
+	// it would also be safe to handle digests with several goroutines (see client.WithConcurrency)
+	d.OnDigestList(func(digestList *p4_v1.DigestList) {
+		log.Debugf("Received DigestList")
+		if err := learnMacs(ctx, p4RtC, digestList); err != nil {
+			// the list is not acked, so the server sends it again after the ack timeout
+			log.Errorf("Error when learning MACs: %v", err)
+			return
 		}
-	}
+		if err := p4RtC.AckDigestList(ctx, digestList); err != nil {
+			log.Errorf("Cannot ack DigestList: %v", err)
+		}
+	})
+	d.OnIdleTimeout(func(notification *p4_v1.IdleTimeoutNotification) {
+		log.Debugf("Received IdleTimeoutNotification")
+		forgetEntries(ctx, p4RtC, notification)
+	})
+	d.OnStreamError(func(streamError *client.StreamError) {
+		log.Errorf("Received StreamError: %v", streamError)
+	})
+	d.OnUnknown(func(message *p4_v1.StreamMessageResponse) {
+		log.Errorf("Received unknown stream message")
+	})
 }
 
 func printPortCounters(p4RtC *client.Client, ports []uint32, period time.Duration, stopCh <-chan struct{}) {
 	ticker := time.NewTicker(period)
 
-	printOne := func(ctx context.Context, name string) error {
-		counts, err := p4RtC.ReadCounterEntryWildcard(ctx, name)
-		if err != nil {
-			return fmt.Errorf("error when reading '%s' counters: %v", name, err)
-		}
+	counterNames := []string{"igPortsCounts", "egPortsCounts"}
+
+	printOne := func(name string, counts []*p4_v1.CounterEntry) {
 		values := make(map[uint32]int64, len(ports))
 		for _, p := range ports {
 			if p >= uint32(len(counts)) {
 				log.Errorf("Port %d is larger than counter size (%d)", p, len(counts))
 				continue
 			}
-			values[p] = counts[p].PacketCount
+			values[p] = counts[p].GetData().GetPacketCount()
 		}
 		log.Debugf("%s: %v", name, values)
-		return nil
 	}
 
 	doPrint := func(ctx context.Context) error {
-		if err := printOne(ctx, "igPortsCounts"); err != nil {
-			return err
+		queries := make([]*client.ReadQuery, 0, len(counterNames))
+		for _, name := range counterNames {
+			queries = append(queries, p4RtC.CounterEntryQuery(name))
+		}
+		results, err := p4RtC.ReadMany(ctx, queries...)
+		if err != nil {
+			return fmt.Errorf("error when reading counters: %v", err)
 		}
-		if err := printOne(ctx, "egPortsCounts"); err != nil {
-			return err
+		for idx, name := range counterNames {
+			printOne(name, results[idx].CounterEntries())
 		}
 		return nil
 	}
@@ -295,44 +318,23 @@ func main() {
 
 	electionID := p4_v1.Uint128{High: 0, Low: 1}
 
-	p4RtC := client.NewClient(c, deviceID, electionID)
-	arbitrationCh := make(chan bool)
-	messageCh := make(chan *p4_v1.StreamMessageResponse, 1000)
-	defer close(messageCh)
-	go p4RtC.Run(stopCh, arbitrationCh, messageCh)
-
-	waitCh := make(chan struct{})
-
+	// digest lists are acked by the OnDigestList handler, only if learnMacs succeeds
+	p4RtC := client.NewClient(c, deviceID, electionID, client.WithDigestAck(client.DigestAckManual, 0))
+	registerStreamHandlers(context.Background(), p4RtC)
 	go func() {
-		sent := false
-		for isPrimary := range arbitrationCh {
-			if isPrimary {
-				log.Infof("We are the primary client!")
-				if !sent {
-					waitCh <- struct{}{}
-					sent = true
-				}
-			} else {
-				log.Infof("We are not the primary client!")
-			}
+		if err := p4RtC.Run(stopCh, nil, nil); err != nil {
+			log.Errorf("Stream channel failed: %v", err)
 		}
 	}()
 
-	// it would also be safe to spawn multiple goroutines to handle messages from the channel
-	go func() {
-		ctx := context.Background()
-		handleStreamMessages(ctx, p4RtC, messageCh)
-	}()
-
 	func() {
 		timeout := 5 * time.Second
 		ctx, cancel := context.WithTimeout(ctx, timeout)
 		defer cancel()
-		select {
-		case <-ctx.Done():
+		if err := p4RtC.WaitForPrimary(ctx); err != nil {
 			log.Fatalf("Could not become the primary client within %v", timeout)
-		case <-waitCh:
 		}
+		log.Infof("We are the primary client!")
 	}()
 
 	log.Info("Setting forwarding pipe")
@@ -36,7 +36,7 @@ func groupToBytes(group string) []byte {
 	return append(padding, b...)
 }
 
-func insertOneGroup(ctx context.Context, p4RtC *client.Client, group string) error {
+func insertOneGroup(ctx context.Context, p4RtC *client.Client, w *client.BatchWriter, group string) (*client.Result, error) {
 	mfs := map[string]client.MatchInterface{
 		"meta.group_id": &client.ExactMatch{
 			Value: groupToBytes(group),
@@ -56,17 +56,44 @@ func insertOneGroup(ctx context.Context, p4RtC *client.Client, group string) err
 	actionSet.AddAction("IngressImpl.set_nhop", [][]byte{nextHopToBytes("nexthop-62")}, 10, watchPort)
 	actionSet.AddAction("IngressImpl.set_nhop", [][]byte{nextHopToBytes("nexthop-64")}, 10, watchPort)
 	entry := p4RtC.NewTableEntry("IngressImpl.wcmp_group", mfs, actionSet.TableAction(), nil)
-	return p4RtC.InsertTableEntry(ctx, entry)
+	return w.Insert(ctx, &p4_v1.Entity{Entity: &p4_v1.Entity_TableEntry{TableEntry: entry}})
 }
 
-func deleteOneGroup(ctx context.Context, p4RtC *client.Client, group string) error {
+func deleteOneGroup(ctx context.Context, p4RtC *client.Client, w *client.BatchWriter, group string) (*client.Result, error) {
 	mfs := map[string]client.MatchInterface{
 		"meta.group_id": &client.ExactMatch{
 			Value: groupToBytes(group),
 		},
 	}
 	entry := p4RtC.NewTableEntry("IngressImpl.wcmp_group", mfs, nil, nil)
-	return p4RtC.DeleteTableEntry(ctx, entry)
+	return w.Delete(ctx, &p4_v1.Entity{Entity: &p4_v1.Entity_TableEntry{TableEntry: entry}})
+}
+
+// writeGroups queues one update per group with writeFn, and waits for all of
+// them to be processed
+func writeGroups(
+	ctx context.Context,
+	p4RtC *client.Client,
+	writeFn func(context.Context, *client.Client, *client.BatchWriter, string) (*client.Result, error),
+	op string,
+) {
+	w := p4RtC.NewBatchWriter(ctx)
+	results := make(map[string]*client.Result)
+	for i := 0; i < 100; i++ {
+		group := fmt.Sprintf("group-%d", i)
+		result, err := writeFn(ctx, p4RtC, w, group)
+		if err != nil {
+			log.Errorf("Error when %s entry for '%s': %v", op, group, err)
+			continue
+		}
+		results[group] = result
+	}
+	w.Close(ctx)
+	for group, result := range results {
+		if err := result.Err(); err != nil {
+			log.Errorf("Error when %s entry for '%s': %v", op, group, err)
+		}
+	}
 }
 
 func main() {
@@ -125,25 +152,9 @@ func main() {
 	electionID := p4_v1.Uint128{High: 0, Low: 1}
 
 	p4RtC := client.NewClient(c, deviceID, electionID)
-	arbitrationCh := make(chan bool)
-	messageCh := make(chan *p4_v1.StreamMessageResponse, 1000)
-	defer close(messageCh)
-	go p4RtC.Run(stopCh, arbitrationCh, messageCh)
-
-	waitCh := make(chan struct{})
-
 	go func() {
-		sent := false
-		for isPrimary := range arbitrationCh {
-			if isPrimary {
-				log.Infof("We are the primary client!")
-				if !sent {
-					waitCh <- struct{}{}
-					sent = true
-				}
-			} else {
-				log.Infof("We are not the primary client!")
-			}
+		if err := p4RtC.Run(stopCh, nil, nil); err != nil {
+			log.Errorf("Stream channel failed: %v", err)
 		}
 	}()
 
@@ -151,11 +162,10 @@ func main() {
 		timeout := 5 * time.Second
 		ctx, cancel := context.WithTimeout(context.Background(), timeout)
 		defer cancel()
-		select {
-		case <-ctx.Done():
+		if err := p4RtC.WaitForPrimary(ctx); err != nil {
 			log.Fatalf("Could not become the primary client within %v", timeout)
-		case <-waitCh:
 		}
+		log.Infof("We are the primary client!")
 	}()
 
 	log.Info("Setting forwarding pipe")
@@ -165,21 +175,11 @@ func main() {
 
 	log.Infof("Installing test groups")
 
-	for i := 0; i < 100; i++ {
-		group := fmt.Sprintf("group-%d", i)
-		if err := insertOneGroup(ctx, p4RtC, group); err != nil {
-			log.Errorf("Error when installing entry for '%s': %v", group, err)
-		}
-	}
+	writeGroups(ctx, p4RtC, insertOneGroup, "installing")
 
 	log.Infof("Deleting test groups")
 
-	for i := 0; i < 100; i++ {
-		group := fmt.Sprintf("group-%d", i)
-		if err := deleteOneGroup(ctx, p4RtC, group); err != nil {
-			log.Errorf("Error when removing entry for '%s': %v", group, err)
-		}
-	}
+	writeGroups(ctx, p4RtC, deleteOneGroup, "removing")
 
 	log.Infof("Done")
 
@@ -9,4 +9,5 @@ require (
 	github.com/stretchr/testify v1.8.0
 	google.golang.org/genproto v0.0.0-20220808204814-fd01256a5276
 	google.golang.org/grpc v1.48.0
+	google.golang.org/protobuf v1.28.1
 )
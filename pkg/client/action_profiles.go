@@ -72,6 +72,24 @@ func (c *Client) DeleteActionProfileMember(ctx context.Context, entry *p4_v1.Act
 	return c.WriteUpdate(ctx, update)
 }
 
+// UpsertActionProfileMember inserts entry, or modifies it if it already exists.
+func (c *Client) UpsertActionProfileMember(ctx context.Context, entry *p4_v1.ActionProfileMember) error {
+	err := c.InsertActionProfileMember(ctx, entry)
+	if IsAlreadyExists(err) {
+		return c.ModifyActionProfileMember(ctx, entry)
+	}
+	return err
+}
+
+// DeleteActionProfileMemberIfExists deletes entry, and succeeds if it does not
+// exist.
+func (c *Client) DeleteActionProfileMemberIfExists(ctx context.Context, entry *p4_v1.ActionProfileMember) error {
+	if err := c.DeleteActionProfileMember(ctx, entry); err != nil && !IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
 func (c *Client) NewActionProfileGroup(
 	actionProfile string,
 	groupID uint32,
@@ -122,3 +140,21 @@ func (c *Client) DeleteActionProfileGroup(ctx context.Context, entry *p4_v1.Acti
 
 	return c.WriteUpdate(ctx, update)
 }
+
+// UpsertActionProfileGroup inserts entry, or modifies it if it already exists.
+func (c *Client) UpsertActionProfileGroup(ctx context.Context, entry *p4_v1.ActionProfileGroup) error {
+	err := c.InsertActionProfileGroup(ctx, entry)
+	if IsAlreadyExists(err) {
+		return c.ModifyActionProfileGroup(ctx, entry)
+	}
+	return err
+}
+
+// DeleteActionProfileGroupIfExists deletes entry, and succeeds if it does not
+// exist.
+func (c *Client) DeleteActionProfileGroupIfExists(ctx context.Context, entry *p4_v1.ActionProfileGroup) error {
+	if err := c.DeleteActionProfileGroup(ctx, entry); err != nil && !IsNotFound(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package client
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Backoff describes an exponential backoff with jitter, as used by Run when
+// re-opening the StreamChannel. The delay before attempt n (starting at 0) is
+// BaseDelay * Multiplier^n, capped at MaxDelay, and randomized by +/- Jitter.
+type Backoff struct {
+	BaseDelay  time.Duration
+	Multiplier float64
+	Jitter     float64
+	MaxDelay   time.Duration
+	// MaxAttempts is the number of consecutive reconnection attempts after
+	// which Run gives up. 0 means retry forever.
+	MaxAttempts int
+}
+
+// DefaultBackoff matches the connection backoff used by gRPC.
+var DefaultBackoff = Backoff{
+	BaseDelay:  1 * time.Second,
+	Multiplier: 1.6,
+	Jitter:     0.2,
+	MaxDelay:   30 * time.Second,
+}
+
+// withDefaults returns b with the fields which would disable the backoff
+// replaced by the ones of defaults: a zero BaseDelay, MaxDelay or Multiplier
+// would make Run reconnect, or writes be retried, in a busy loop.
+func (b Backoff) withDefaults(defaults Backoff) Backoff {
+	if b.BaseDelay <= 0 {
+		b.BaseDelay = defaults.BaseDelay
+	}
+	if b.Multiplier < 1 {
+		b.Multiplier = defaults.Multiplier
+	}
+	if b.MaxDelay <= 0 {
+		b.MaxDelay = defaults.MaxDelay
+	}
+	if b.MaxDelay < b.BaseDelay {
+		b.MaxDelay = b.BaseDelay
+	}
+	if b.Jitter < 0 {
+		b.Jitter = 0
+	} else if b.Jitter > 1 {
+		b.Jitter = 1
+	}
+	return b
+}
+
+func (b Backoff) delay(retries int) time.Duration {
+	if retries == 0 {
+		return b.jitter(b.BaseDelay)
+	}
+	backoff, max := float64(b.BaseDelay), float64(b.MaxDelay)
+	for backoff < max && retries > 0 {
+		backoff *= b.Multiplier
+		retries--
+	}
+	if backoff > max {
+		backoff = max
+	}
+	return b.jitter(time.Duration(backoff))
+}
+
+func (b Backoff) jitter(d time.Duration) time.Duration {
+	if b.Jitter == 0 {
+		return d
+	}
+	//nolint:gosec // G404 jitter does not need a cryptographically secure source
+	d = time.Duration(float64(d) * (1 + b.Jitter*(rand.Float64()*2-1)))
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+func (b Backoff) exhausted(retries int) bool {
+	return b.MaxAttempts > 0 && retries >= b.MaxAttempts
+}
@@ -0,0 +1,32 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	p4_v1 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+func TestReconnectBackoff(t *testing.T) {
+	c := NewClient(&fakeP4RuntimeClient{}, 1, p4_v1.Uint128{Low: 1}, WithReconnectBackoff(Backoff{BaseDelay: 2 * time.Second, MaxAttempts: 3}))
+	assert.Equal(t, Backoff{
+		BaseDelay:   2 * time.Second,
+		Multiplier:  DefaultBackoff.Multiplier,
+		MaxDelay:    DefaultBackoff.MaxDelay,
+		MaxAttempts: 3,
+	}, c.ReconnectBackoff)
+	assert.Equal(t, 2*time.Second, c.ReconnectBackoff.delay(0))
+	assert.Equal(t, 3200*time.Millisecond, c.ReconnectBackoff.delay(1))
+	assert.Equal(t, DefaultBackoff.MaxDelay, c.ReconnectBackoff.delay(100))
+
+	backoff := Backoff{BaseDelay: time.Minute, Multiplier: 2, Jitter: 2}.withDefaults(DefaultBackoff)
+	assert.Equal(t, Backoff{BaseDelay: time.Minute, Multiplier: 2, Jitter: 1, MaxDelay: time.Minute}, backoff)
+	// no jitter is valid
+	assert.Equal(t, Backoff{
+		BaseDelay:  DefaultBackoff.BaseDelay,
+		Multiplier: DefaultBackoff.Multiplier,
+		MaxDelay:   DefaultBackoff.MaxDelay,
+	}, Backoff{}.withDefaults(DefaultBackoff))
+}
@@ -0,0 +1,196 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	//nolint:staticcheck // SA1019 To be resolved later
+	//lint:ignore SA1019 This line added for support golint version of VSC
+	"github.com/golang/protobuf/proto"
+
+	p4_v1 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+const (
+	defaultBatchMaxUpdates = 500
+	// well below the 4MB default max message size of gRPC servers
+	defaultBatchMaxBytes = 1 << 20
+	defaultBatchLinger   = 10 * time.Millisecond
+)
+
+type BatchWriterOptions struct {
+	// MaxUpdates is the number of updates after which the batch is sent.
+	MaxUpdates int
+	// MaxBytes is the approximate size of the WriteRequest, in bytes, after
+	// which the batch is sent.
+	MaxBytes int
+	// Linger is how long an update can wait for more updates before the
+	// batch is sent. 0 disables the timer: the batch is only sent when full
+	// or when Flush is called.
+	Linger time.Duration
+}
+
+var defaultBatchWriterOptions = BatchWriterOptions{
+	MaxUpdates: defaultBatchMaxUpdates,
+	MaxBytes:   defaultBatchMaxBytes,
+	Linger:     defaultBatchLinger,
+}
+
+func WithBatchMaxUpdates(maxUpdates int) func(*BatchWriterOptions) {
+	return func(options *BatchWriterOptions) {
+		options.MaxUpdates = maxUpdates
+	}
+}
+
+func WithBatchMaxBytes(maxBytes int) func(*BatchWriterOptions) {
+	return func(options *BatchWriterOptions) {
+		options.MaxBytes = maxBytes
+	}
+}
+
+func WithBatchLinger(linger time.Duration) func(*BatchWriterOptions) {
+	return func(options *BatchWriterOptions) {
+		options.Linger = linger
+	}
+}
+
+// BatchWriter collects updates and sends them in as few WriteRequests as
+// possible, in the order in which they were added. Each update gets a Result,
+// which completes once the WriteRequest containing it has been processed, and
+// reports the *UpdateError for the update if the server sent per-update
+// errors.
+// A BatchWriter is safe for concurrent use.
+type BatchWriter struct {
+	client  *Client
+	options BatchWriterOptions
+	// ctx is used for the WriteRequests sent when the Linger timer fires
+	ctx context.Context
+
+	// held while a batch is being sent, so that batches are sent in order
+	mutex   sync.Mutex
+	updates []*p4_v1.Update
+	results []*Result
+	size    int
+	timer   *time.Timer
+	batchID uint64 // incremented when a batch is sent
+	closed  bool
+}
+
+// ErrBatchWriterClosed is returned when adding an update to a closed
+// BatchWriter.
+var ErrBatchWriterClosed = fmt.Errorf("BatchWriter is closed")
+
+// NewBatchWriter creates a BatchWriter. ctx is used for the batches sent when
+// the Linger timer fires; the other batches are sent with the context of the
+// call which triggers them.
+func (c *Client) NewBatchWriter(ctx context.Context, optionsModifierFns ...func(*BatchWriterOptions)) *BatchWriter {
+	options := defaultBatchWriterOptions
+	for _, fn := range optionsModifierFns {
+		fn(&options)
+	}
+	if options.MaxUpdates < 1 {
+		options.MaxUpdates = 1
+	}
+	return &BatchWriter{
+		client:  c,
+		options: options,
+		ctx:     ctx,
+	}
+}
+
+func (w *BatchWriter) Insert(ctx context.Context, entity *p4_v1.Entity) (*Result, error) {
+	return w.Write(ctx, &p4_v1.Update{Type: p4_v1.Update_INSERT, Entity: entity})
+}
+
+func (w *BatchWriter) Modify(ctx context.Context, entity *p4_v1.Entity) (*Result, error) {
+	return w.Write(ctx, &p4_v1.Update{Type: p4_v1.Update_MODIFY, Entity: entity})
+}
+
+func (w *BatchWriter) Delete(ctx context.Context, entity *p4_v1.Entity) (*Result, error) {
+	return w.Write(ctx, &p4_v1.Update{Type: p4_v1.Update_DELETE, Entity: entity})
+}
+
+// Write adds update to the current batch, and sends the batch if it is full.
+// The returned error is only for the update which could not be added; errors
+// for the update itself are reported by the Result.
+func (w *BatchWriter) Write(ctx context.Context, update *p4_v1.Update) (*Result, error) {
+	size := proto.Size(update)
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closed {
+		return nil, ErrBatchWriterClosed
+	}
+	if len(w.updates) > 0 && w.size+size > w.options.MaxBytes {
+		w.flushLocked(ctx)
+	}
+	result := newResult()
+	w.updates = append(w.updates, update)
+	w.results = append(w.results, result)
+	w.size += size
+	if len(w.updates) >= w.options.MaxUpdates || w.size >= w.options.MaxBytes {
+		w.flushLocked(ctx)
+	} else if w.timer == nil && w.options.Linger > 0 {
+		batchID := w.batchID
+		w.timer = time.AfterFunc(w.options.Linger, func() {
+			w.lingerExpired(batchID)
+		})
+	}
+	return result, nil
+}
+
+func (w *BatchWriter) lingerExpired(batchID uint64) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	// the batch may have been sent while we were waiting for the mutex
+	if batchID != w.batchID {
+		return
+	}
+	w.flushLocked(w.ctx)
+}
+
+// Flush sends the current batch, if any, and returns the error for the
+// WriteRequest.
+func (w *BatchWriter) Flush(ctx context.Context) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.flushLocked(ctx)
+}
+
+// Close sends the current batch, if any, and prevents any more updates from
+// being added.
+func (w *BatchWriter) Close(ctx context.Context) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.closed = true
+	return w.flushLocked(ctx)
+}
+
+func (w *BatchWriter) flushLocked(ctx context.Context) error {
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+	if len(w.updates) == 0 {
+		return nil
+	}
+	updates, results := w.updates, w.results
+	w.updates, w.results, w.size = nil, nil, 0
+	w.batchID++
+	err := w.client.WriteManyUpdate(ctx, updates)
+	if writeErr, ok := err.(*WriteError); ok {
+		for idx, result := range results {
+			if updateErr := writeErr.Updates[idx]; updateErr.Failed() {
+				result.resolve(updateErr)
+			} else {
+				result.resolve(nil)
+			}
+		}
+		return err
+	}
+	for _, result := range results {
+		result.resolve(err)
+	}
+	return err
+}
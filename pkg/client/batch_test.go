@@ -0,0 +1,85 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	p4_v1 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+func TestBatchWriter(t *testing.T) {
+	var mutex sync.Mutex
+	var batches [][]uint32
+	p4RtClient := &fakeP4RuntimeClient{
+		writeFn: func(ctx context.Context, in *p4_v1.WriteRequest, opts ...grpc.CallOption) (*p4_v1.WriteResponse, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			var batch []uint32
+			for _, update := range in.Updates {
+				batch = append(batch, update.GetEntity().GetPacketReplicationEngineEntry().GetMulticastGroupEntry().GetMulticastGroupId())
+			}
+			batches = append(batches, batch)
+			if len(batches) == 2 {
+				return nil, status.Error(codes.Unknown, "batch failed")
+			}
+			return &p4_v1.WriteResponse{}, nil
+		},
+	}
+	fakeClient := newTestClient(p4RtClient, nil)
+	ctx := context.Background()
+	newEntity := func(mgid uint32) *p4_v1.Entity {
+		return &p4_v1.Entity{Entity: &p4_v1.Entity_PacketReplicationEngineEntry{
+			PacketReplicationEngineEntry: &p4_v1.PacketReplicationEngineEntry{
+				Type: &p4_v1.PacketReplicationEngineEntry_MulticastGroupEntry{
+					MulticastGroupEntry: &p4_v1.MulticastGroupEntry{MulticastGroupId: mgid},
+				},
+			},
+		}}
+	}
+
+	w := fakeClient.NewBatchWriter(ctx, WithBatchMaxUpdates(3), WithBatchLinger(0))
+	var results []*Result
+	for mgid := uint32(1); mgid <= 7; mgid++ {
+		result, err := w.Insert(ctx, newEntity(mgid))
+		require.NoError(t, err)
+		results = append(results, result)
+	}
+	require.NoError(t, w.Close(ctx))
+	_, err := w.Insert(ctx, newEntity(8))
+	assert.Equal(t, ErrBatchWriterClosed, err)
+
+	assert.Equal(t, [][]uint32{{1, 2, 3}, {4, 5, 6}, {7}}, batches)
+	for idx, result := range results {
+		if idx >= 3 && idx < 6 {
+			assert.Equal(t, codes.Unknown, status.Code(result.Err()))
+		} else {
+			assert.NoError(t, result.Err())
+		}
+	}
+
+	// the linger timer sends an incomplete batch
+	w = fakeClient.NewBatchWriter(ctx, WithBatchLinger(time.Millisecond))
+	result, err := w.Delete(ctx, newEntity(9))
+	require.NoError(t, err)
+	waitCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	assert.NoError(t, result.Wait(waitCtx))
+
+	// a batch is sent before it can go over MaxBytes
+	size := len(batches)
+	w = fakeClient.NewBatchWriter(ctx, WithBatchMaxBytes(15), WithBatchLinger(0))
+	for mgid := uint32(10); mgid <= 12; mgid++ {
+		_, err := w.Modify(ctx, newEntity(mgid))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Flush(ctx))
+	assert.Equal(t, [][]uint32{{10}, {11}, {12}}, batches[size:])
+}
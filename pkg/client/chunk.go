@@ -0,0 +1,156 @@
+package client
+
+import (
+	//nolint:staticcheck // SA1019 To be resolved later
+	//lint:ignore SA1019 This line added for support golint version of VSC
+	"github.com/golang/protobuf/proto"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	p4_v1 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+const (
+	// default max receive message size of gRPC servers
+	defaultMaxRequestBytes = 4 << 20
+)
+
+// WithMaxRequestBytes sets the size above which Write and Read requests are
+// split into several requests, see MaxRequestBytes.
+func WithMaxRequestBytes(maxBytes int) func(*ClientOptions) {
+	return func(options *ClientOptions) {
+		options.MaxRequestBytes = maxBytes
+	}
+}
+
+// chunkEnds splits the n elements of a repeated message field into
+// consecutive chunks which fit in maxBytes once serialized, with baseSize
+// being the size of the rest of the message. It returns the end index of each
+// chunk. An element which does not fit on its own gets its own chunk.
+func chunkEnds(n int, elemSize func(int) int, baseSize, maxBytes int) []int {
+	var ends []int
+	size := baseSize
+	for idx := 0; idx < n; idx++ {
+		// 1 byte for the tag, as the field numbers are < 16
+		s := 1 + protowire.SizeBytes(elemSize(idx))
+		if size+s > maxBytes && size > baseSize {
+			ends = append(ends, idx)
+			size = baseSize
+		}
+		size += s
+	}
+	return append(ends, n)
+}
+
+// splitWriteRequest returns the requests to send for req, in order. Only
+// CONTINUE_ON_ERROR requests can be split, as the other atomicity modes apply
+// to the request as a whole.
+func (c *Client) splitWriteRequest(req *p4_v1.WriteRequest) []*p4_v1.WriteRequest {
+	if c.MaxRequestBytes <= 0 || proto.Size(req) <= c.MaxRequestBytes {
+		return []*p4_v1.WriteRequest{req}
+	}
+	if req.Atomicity != p4_v1.WriteRequest_CONTINUE_ON_ERROR {
+		log.Warnf("WriteRequest is larger than %d bytes but cannot be split with atomicity %v", c.MaxRequestBytes, req.Atomicity)
+		return []*p4_v1.WriteRequest{req}
+	}
+	base := proto.Clone(req).(*p4_v1.WriteRequest)
+	base.Updates = nil
+	ends := chunkEnds(len(req.Updates), func(idx int) int {
+		return proto.Size(req.Updates[idx])
+	}, proto.Size(base), c.MaxRequestBytes)
+	requests := make([]*p4_v1.WriteRequest, 0, len(ends))
+	start := 0
+	for _, end := range ends {
+		chunk := proto.Clone(base).(*p4_v1.WriteRequest)
+		chunk.Updates = req.Updates[start:end]
+		requests = append(requests, chunk)
+		start = end
+	}
+	return requests
+}
+
+// newReadRequests returns the requests to send to read entities, in order.
+func (c *Client) newReadRequests(entities []*p4_v1.Entity) []*p4_v1.ReadRequest {
+	base := &p4_v1.ReadRequest{
+		DeviceId: c.deviceID,
+		Role:     c.RoleName,
+	}
+	if c.MaxRequestBytes <= 0 {
+		base.Entities = entities
+		return []*p4_v1.ReadRequest{base}
+	}
+	ends := chunkEnds(len(entities), func(idx int) int {
+		return proto.Size(entities[idx])
+	}, proto.Size(base), c.MaxRequestBytes)
+	requests := make([]*p4_v1.ReadRequest, 0, len(ends))
+	start := 0
+	for _, end := range ends {
+		requests = append(requests, &p4_v1.ReadRequest{
+			DeviceId: base.DeviceId,
+			Role:     base.Role,
+			Entities: entities[start:end],
+		})
+		start = end
+	}
+	return requests
+}
+
+// writeChunks sends requests in order and merges their errors in a
+// *WriteError covering all the updates, with indexes relative to the original
+// request. Like the server for a single CONTINUE_ON_ERROR request, it keeps
+// going when some updates fail, but it stops if a request fails as a whole,
+// and its error is kept in the Cause of the *WriteError.
+func (c *Client) writeChunks(requests []*p4_v1.WriteRequest, write func(*p4_v1.WriteRequest) error) error {
+	var merged *WriteError
+	var updateErrs []*UpdateError
+	for idx, req := range requests {
+		offset := len(updateErrs)
+		err := write(req)
+		if err == nil {
+			for i, update := range req.Updates {
+				updateErrs = append(updateErrs, c.newUpdateError(offset+i, update, &p4_v1.Error{CanonicalCode: int32(codes.OK)}))
+			}
+			continue
+		}
+		writeErr, ok := err.(*WriteError)
+		if ok {
+			for _, u := range writeErr.Updates {
+				u.Index += offset
+				updateErrs = append(updateErrs, u)
+			}
+			if merged == nil {
+				merged = &WriteError{Status: writeErr.Status}
+			}
+			continue
+		}
+		// the status of the updates of this request is unknown, and the
+		// following requests are not sent
+		st := status.Convert(err)
+		for i, update := range req.Updates {
+			updateErrs = append(updateErrs, c.newUpdateError(offset+i, update, &p4_v1.Error{
+				CanonicalCode: int32(st.Code()),
+				Message:       st.Message(),
+			}))
+		}
+		for _, next := range requests[idx+1:] {
+			for _, update := range next.Updates {
+				updateErrs = append(updateErrs, c.newUpdateError(len(updateErrs), update, &p4_v1.Error{
+					CanonicalCode: int32(codes.Aborted),
+					Message:       "not sent because a previous WriteRequest failed",
+				}))
+			}
+		}
+		if merged == nil {
+			merged = &WriteError{Status: st}
+		}
+		merged.Cause = err
+		break
+	}
+	if merged == nil {
+		return nil
+	}
+	merged.Updates = updateErrs
+	return merged
+}
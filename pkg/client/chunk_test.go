@@ -0,0 +1,111 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	//nolint:staticcheck // SA1019 To be resolved later
+	//lint:ignore SA1019 This line added for support golint version of VSC
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	p4_v1 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+// TestWriteChunks ensures that a WriteRequest which is too large is split in order, and that the
+// per-update errors are merged with the indexes of the original request.
+func TestWriteChunks(t *testing.T) {
+	newUpdate := func(mgid uint32) *p4_v1.Update {
+		replicas := make([]*p4_v1.Replica, 10)
+		for idx := range replicas {
+			replicas[idx] = &p4_v1.Replica{EgressPort: uint32(idx + 1), Instance: 1}
+		}
+		return &p4_v1.Update{Type: p4_v1.Update_INSERT, Entity: &p4_v1.Entity{
+			Entity: &p4_v1.Entity_PacketReplicationEngineEntry{
+				PacketReplicationEngineEntry: &p4_v1.PacketReplicationEngineEntry{
+					Type: &p4_v1.PacketReplicationEngineEntry_MulticastGroupEntry{
+						MulticastGroupEntry: &p4_v1.MulticastGroupEntry{MulticastGroupId: mgid, Replicas: replicas},
+					},
+				},
+			},
+		}}
+	}
+	var requests [][]uint32
+	var fakeClient *Client
+	p4RtClient := &fakeP4RuntimeClient{
+		writeFn: func(ctx context.Context, in *p4_v1.WriteRequest, opts ...grpc.CallOption) (*p4_v1.WriteResponse, error) {
+			if in.Atomicity == p4_v1.WriteRequest_CONTINUE_ON_ERROR {
+				assert.LessOrEqual(t, proto.Size(in), fakeClient.MaxRequestBytes)
+			}
+			var mgids []uint32
+			var details []*p4_v1.Error
+			failed := false
+			for _, update := range in.Updates {
+				mgid := update.GetEntity().GetPacketReplicationEngineEntry().GetMulticastGroupEntry().GetMulticastGroupId()
+				mgids = append(mgids, mgid)
+				code := codes.OK
+				if mgid == 4 {
+					code = codes.AlreadyExists
+					failed = true
+				}
+				details = append(details, &p4_v1.Error{CanonicalCode: int32(code)})
+			}
+			requests = append(requests, mgids)
+			if !failed {
+				return &p4_v1.WriteResponse{}, nil
+			}
+			st := status.New(codes.Unknown, "write failed")
+			for _, detail := range details {
+				var err error
+				st, err = st.WithDetails(detail)
+				require.NoError(t, err)
+			}
+			return nil, st.Err()
+		},
+	}
+	fakeClient = newTestClient(p4RtClient, nil)
+	WithMaxRequestBytes(200)(&fakeClient.ClientOptions)
+
+	var updates []*p4_v1.Update
+	for mgid := uint32(1); mgid <= 7; mgid++ {
+		updates = append(updates, newUpdate(mgid))
+	}
+	err := fakeClient.WriteManyUpdate(context.Background(), updates)
+	assert.Equal(t, [][]uint32{{1, 2}, {3, 4}, {5, 6}, {7}}, requests)
+	var writeErr *WriteError
+	require.True(t, errors.As(err, &writeErr))
+	assert.Len(t, writeErr.Updates, 7)
+	failed := writeErr.Failed()
+	require.Len(t, failed, 1)
+	assert.Equal(t, 3, failed[0].Index)
+	assert.Same(t, updates[3], failed[0].Update)
+
+	// a request which fails as a whole
+	writeFn := p4RtClient.writeFn
+	for _, requestErr := range []error{ErrNotPrimary, &UnsupportedAtomicityError{Atomicity: DataplaneAtomic}} {
+		p4RtClient.writeFn = func(ctx context.Context, in *p4_v1.WriteRequest, opts ...grpc.CallOption) (*p4_v1.WriteResponse, error) {
+			if in.Updates[0].GetEntity().GetPacketReplicationEngineEntry().GetMulticastGroupEntry().GetMulticastGroupId() == 3 {
+				return nil, requestErr
+			}
+			return &p4_v1.WriteResponse{}, nil
+		}
+		err = fakeClient.WriteManyUpdate(context.Background(), updates)
+		require.True(t, errors.As(err, &writeErr))
+		assert.Len(t, writeErr.Failed(), 5)
+		assert.True(t, errors.Is(err, requestErr))
+		assert.Equal(t, status.Code(requestErr), status.Code(err))
+	}
+	var atomicityErr *UnsupportedAtomicityError
+	assert.True(t, errors.As(err, &atomicityErr))
+	p4RtClient.writeFn = writeFn
+
+	// the request cannot be split with ROLLBACK_ON_ERROR
+	requests = nil
+	assert.NoError(t, fakeClient.WriteManyUpdate(context.Background(), updates[4:], WithAtomicity(RollbackOnError)))
+	assert.Equal(t, [][]uint32{{5, 6, 7}}, requests)
+}
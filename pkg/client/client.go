@@ -4,9 +4,14 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"sync"
+	"time"
 
+	"github.com/golang/protobuf/ptypes/any"
 	log "github.com/sirupsen/logrus"
 	code "google.golang.org/genproto/googleapis/rpc/code"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	p4_config_v1 "github.com/p4lang/p4runtime/go/p4/config/v1"
 	p4_v1 "github.com/p4lang/p4runtime/go/p4/v1"
@@ -18,23 +23,142 @@ const (
 
 type ClientOptions struct {
 	CanonicalBytestrings bool
+	// ReconnectBackoff controls how Run re-opens the StreamChannel after it
+	// is lost. The fields which are not set, or which are invalid, are taken
+	// from DefaultBackoff.
+	ReconnectBackoff Backoff
+	// DisableReconnect makes Run return as soon as the StreamChannel is lost.
+	DisableReconnect bool
+	// ConnectionStateFn, if set, is called by Run every time the state of the
+	// StreamChannel changes. It is called synchronously and must not block.
+	ConnectionStateFn func(ConnectionState)
+	// DigestAckMode and MaxOutstandingDigestLists control digest
+	// acknowledgement, see WithDigestAck.
+	DigestAckMode             DigestAckMode
+	MaxOutstandingDigestLists int
+	// StreamErrorWindow is how long SendPacketOutAsync and AckDigestListAsync
+	// wait for a StreamError before reporting success.
+	StreamErrorWindow time.Duration
+	// SendQueueSize is the number of messages which can be queued for the
+	// StreamChannel, and SendQueuePolicy decides what happens when the queue
+	// is full, see WithSendQueue.
+	SendQueueSize   int
+	SendQueuePolicy OverflowPolicy
+	// WriteRetryPolicy, if set, is used to retry writes which fail with a
+	// transient error, see WithWriteRetry.
+	WriteRetryPolicy *RetryPolicy
+	// MaxRequestBytes is the size above which Write and Read requests are
+	// split into several requests, so that they are not rejected by the
+	// server. 0 disables splitting.
+	MaxRequestBytes int
+	// RoleName and RoleConfig select the controller role used for
+	// arbitration and for all Write, Read and SetForwardingPipelineConfig
+	// requests. An empty RoleName is the default role, which has full
+	// pipeline access.
+	RoleName   string
+	RoleConfig *any.Any
 }
 
 var defaultClientOptions = ClientOptions{
 	CanonicalBytestrings: true,
+	ReconnectBackoff:     DefaultBackoff,
+	StreamErrorWindow:    defaultStreamErrorWindow,
+	SendQueueSize:        defaultSendQueueSize,
+	SendQueuePolicy:      OverflowBlock,
+	MaxRequestBytes:      defaultMaxRequestBytes,
 }
 
 func DisableCanonicalBytestrings(options *ClientOptions) {
 	options.CanonicalBytestrings = false
 }
 
+func DisableReconnect(options *ClientOptions) {
+	options.DisableReconnect = true
+}
+
+func WithReconnectBackoff(backoff Backoff) func(*ClientOptions) {
+	return func(options *ClientOptions) {
+		options.ReconnectBackoff = backoff
+	}
+}
+
+func WithStreamErrorWindow(window time.Duration) func(*ClientOptions) {
+	return func(options *ClientOptions) {
+		options.StreamErrorWindow = window
+	}
+}
+
+// WithRole sets the role of the client. config is opaque to the client and is
+// interpreted by the server; it can be nil.
+func WithRole(name string, config *any.Any) func(*ClientOptions) {
+	return func(options *ClientOptions) {
+		options.RoleName = name
+		options.RoleConfig = config
+	}
+}
+
+func WithConnectionStateFn(fn func(ConnectionState)) func(*ClientOptions) {
+	return func(options *ClientOptions) {
+		options.ConnectionStateFn = fn
+	}
+}
+
+// ConnectionState is the state of the StreamChannel managed by Run.
+type ConnectionState int
+
+const (
+	StateConnecting ConnectionState = iota
+	StateConnected
+	StatePrimary
+	StateBackup
+	StateDisconnected
+)
+
+func (s ConnectionState) String() string {
+	switch s {
+	case StateConnecting:
+		return "connecting"
+	case StateConnected:
+		return "connected"
+	case StatePrimary:
+		return "primary"
+	case StateBackup:
+		return "backup"
+	case StateDisconnected:
+		return "disconnected"
+	default:
+		return "unknown"
+	}
+}
+
 type Client struct {
 	ClientOptions
 	p4_v1.P4RuntimeClient
 	deviceID     uint64
 	electionID   p4_v1.Uint128
 	p4Info       *p4_config_v1.P4Info
-	streamSendCh chan *p4_v1.StreamMessageRequest
+	streamSendCh chan *streamRequest
+	sendStats    sendQueueCounters
+	dispatcher   *Dispatcher
+	digests      *digestTracker
+	streamErrors *streamErrorTracker
+
+	// Run and arbitration state
+	stateMutex        sync.Mutex
+	running           bool
+	runCancel         context.CancelFunc
+	runDone           chan struct{} // closed when Run returns
+	arbitrated        bool          // arbitration received on the current stream
+	primary           bool
+	primaryElectionID *p4_v1.Uint128
+	primaryCh         chan struct{} // closed when the client becomes primary
+}
+
+// RoleStatus is the arbitration status of the client for its role, as last
+// reported by the server on the StreamChannel.
+type RoleStatus struct {
+	Role    string
+	Primary bool
 }
 
 func NewClient(
@@ -47,99 +171,455 @@ func NewClient(
 	for _, fn := range optionsModifierFns {
 		fn(&options)
 	}
-	return &Client{
+	if options.SendQueueSize < 1 {
+		options.SendQueueSize = 1
+	}
+	options.ReconnectBackoff = options.ReconnectBackoff.withDefaults(DefaultBackoff)
+	c := &Client{
 		ClientOptions:   options,
 		P4RuntimeClient: p4RuntimeClient,
 		deviceID:        deviceID,
 		electionID:      electionID,
-		streamSendCh:    make(chan *p4_v1.StreamMessageRequest, 1000), // TODO: should be configurable
+		streamSendCh:    make(chan *streamRequest, options.SendQueueSize),
+		digests:         newDigestTracker(),
+		streamErrors:    newStreamErrorTracker(),
+		primaryCh:       make(chan struct{}),
+	}
+	c.dispatcher = newDispatcher(c)
+	return c
+}
+
+// Role returns the name of the role used by the client; the default role is
+// the empty string.
+func (c *Client) Role() string {
+	return c.RoleName
+}
+
+// RoleStatus returns whether the client is currently primary for its role.
+func (c *Client) RoleStatus() RoleStatus {
+	return RoleStatus{Role: c.RoleName, Primary: c.IsPrimary()}
+}
+
+// ErrNotPrimary is returned by writes attempted while Run is running but the
+// client is not the primary for its role.
+var ErrNotPrimary = status.Error(codes.PermissionDenied, "client is not the primary for its role")
+
+// IsPrimary returns true if the server reported on the StreamChannel that the
+// client is the primary for its role.
+func (c *Client) IsPrimary() bool {
+	c.stateMutex.Lock()
+	defer c.stateMutex.Unlock()
+	return c.primary
+}
+
+// CurrentPrimaryElectionID returns the election ID of the primary for the
+// role of the client, as last reported by the server, or nil if there is no
+// primary or the StreamChannel is down.
+func (c *Client) CurrentPrimaryElectionID() *p4_v1.Uint128 {
+	c.stateMutex.Lock()
+	defer c.stateMutex.Unlock()
+	if c.primaryElectionID == nil {
+		return nil
+	}
+	return &p4_v1.Uint128{High: c.primaryElectionID.High, Low: c.primaryElectionID.Low}
+}
+
+// WaitForPrimary blocks until the client is the primary for its role, or
+// until ctx is done. Run must be running for the client to become primary.
+func (c *Client) WaitForPrimary(ctx context.Context) error {
+	c.stateMutex.Lock()
+	primaryCh := c.primaryCh
+	c.stateMutex.Unlock()
+	select {
+	case <-primaryCh:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// checkPrimary lets writes fail fast when we know that the server will reject
+// them. Without Run there is no arbitration state, and the server decides.
+func (c *Client) checkPrimary() error {
+	c.stateMutex.Lock()
+	defer c.stateMutex.Unlock()
+	if c.running && !c.primary {
+		return ErrNotPrimary
+	}
+	return nil
+}
+
+var errAlreadyRunning = fmt.Errorf("run is already running for this client")
+
+func (c *Client) startRun(cancel context.CancelFunc) error {
+	c.stateMutex.Lock()
+	defer c.stateMutex.Unlock()
+	if c.running {
+		return errAlreadyRunning
+	}
+	c.running = true
+	c.runCancel = cancel
+	c.runDone = make(chan struct{})
+	return nil
+}
+
+func (c *Client) stopRun() {
+	c.stateMutex.Lock()
+	defer c.stateMutex.Unlock()
+	c.running = false
+	c.runCancel = nil
+	close(c.runDone)
+}
+
+// Close stops Run, if it is running, and waits for it to return. The client
+// can still be used for RPCs afterwards, and Run can be called again.
+func (c *Client) Close() error {
+	c.stateMutex.Lock()
+	cancel, done := c.runCancel, c.runDone
+	c.stateMutex.Unlock()
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+	<-done
+	return nil
+}
+
+// setArbitration updates the arbitration state from the
+// MasterArbitrationUpdate sent by the server, or resets it if update is nil.
+// It returns true if the client is primary.
+func (c *Client) setArbitration(update *p4_v1.MasterArbitrationUpdate) bool {
+	isPrimary := update != nil && update.GetStatus().GetCode() == int32(code.Code_OK)
+	electionID := update.GetElectionId()
+	c.stateMutex.Lock()
+	defer c.stateMutex.Unlock()
+	if isPrimary && !c.primary {
+		close(c.primaryCh)
+	} else if !isPrimary && c.primary {
+		c.primaryCh = make(chan struct{})
+	}
+	c.arbitrated = update != nil
+	c.primary = isPrimary
+	c.primaryElectionID = electionID
+	return isPrimary
+}
+
+// arbitrationPending returns true while Run waits for the arbitration on a new
+// stream, e.g. when reconnecting after a restart of the switch agent. The
+// client is not primary then, but it may be again once the stream is up.
+func (c *Client) arbitrationPending() bool {
+	c.stateMutex.Lock()
+	defer c.stateMutex.Unlock()
+	return c.running && !c.arbitrated
+}
+
+// role returns the Role to include in the MasterArbitrationUpdate, which must
+// be unset for the default role.
+func (c *Client) role() *p4_v1.Role {
+	if c.RoleName == "" {
+		return nil
+	}
+	return &p4_v1.Role{
+		Name:   c.RoleName,
+		Config: c.RoleConfig,
 	}
 }
 
+func (c *Client) setConnectionState(state ConnectionState) {
+	if c.RoleName != "" {
+		log.Debugf("StreamChannel is %v for role %q", state, c.RoleName)
+	} else {
+		log.Debugf("StreamChannel is %v", state)
+	}
+	if state == StateDisconnected {
+		c.setArbitration(nil)
+	}
+	if c.ConnectionStateFn != nil {
+		c.ConnectionStateFn(state)
+	}
+}
+
+// StreamChannelError is returned by Run when the StreamChannel fails. It wraps
+// the error returned by the gRPC stream and preserves its status code, which
+// can be retrieved with status.Code.
+type StreamChannelError struct {
+	Op  string // one of "open", "send" or "receive"
+	Err error
+}
+
+func (e *StreamChannelError) Error() string {
+	if e.Err == io.EOF {
+		return fmt.Sprintf("stream %s failed: stream closed by server", e.Op)
+	}
+	return fmt.Sprintf("stream %s failed: %v", e.Op, e.Err)
+}
+
+func (e *StreamChannelError) Unwrap() error {
+	return e.Err
+}
+
+func (e *StreamChannelError) GRPCStatus() *status.Status {
+	if e.Err == io.EOF {
+		return status.New(codes.Unavailable, e.Error())
+	}
+	return status.Convert(e.Err)
+}
+
+// isRetryableStreamError returns true if the StreamChannel failure is likely to
+// be transient (e.g. the switch restarted), in which case Run reconnects.
+func isRetryableStreamError(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.Internal, codes.Aborted:
+		return true
+	default:
+		return false
+	}
+}
+
+// Run is like RunContext, but runs until stopCh is closed.
 func (c *Client) Run(
 	stopCh <-chan struct{},
 	arbitrationCh chan<- bool,
 	messageCh chan<- *p4_v1.StreamMessageResponse, // all other stream messages besides arbitration
 ) error {
-	// we use an empty Context which is never cancelled and has no
-	// deadline. We will close the stream by calling CloseSend when the
-	// caller closes the stopCh channel.
-	stream, err := c.StreamChannel(context.Background())
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		select {
+		case <-stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return c.RunContext(ctx, arbitrationCh, messageCh)
+}
+
+// RunContext opens the StreamChannel, sends the MasterArbitrationUpdate and
+// then forwards stream messages until ctx is done or Close is called.
+// Messages are handed to the Dispatcher handlers first, and then sent on
+// messageCh or arbitrationCh. Whenever the StreamChannel is lost because of a
+// transient error (the server closed the stream, or the gRPC code is
+// UNAVAILABLE, INTERNAL or ABORTED), RunContext re-opens it according to
+// ReconnectBackoff and sends the MasterArbitrationUpdate again, with the same
+// device ID and election ID. The arbitration state reported by the server can
+// be queried with IsPrimary, WaitForPrimary and CurrentPrimaryElectionID.
+//
+// RunContext returns nil when ctx is done. Any other failure ends it with a
+// *StreamChannelError. Only one RunContext can run at a time for a client.
+// Before returning, RunContext waits for all its goroutines, including the
+// Dispatcher handlers, to exit, discards the messages which are still queued
+// for sending (their Result, if any, reports ErrStreamClosed), and then closes
+// messageCh followed by arbitrationCh (when not nil): once arbitrationCh is
+// closed, no more messages will be delivered. Callers must not close these
+// channels themselves. If RunContext is already running, it returns an error
+// right away and leaves the channels open.
+func (c *Client) RunContext(
+	ctx context.Context,
+	arbitrationCh chan<- bool,
+	messageCh chan<- *p4_v1.StreamMessageResponse, // all other stream messages besides arbitration
+) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if err := c.startRun(cancel); err != nil {
+		// the channels may be the ones of the RunContext which is running
+		return err
+	}
+	defer c.stopRun()
+	defer closeStreamChannels(arbitrationCh, messageCh)
+
+	// the messages queued by the handlers while they stop must be drained
+	// too, so that they are not sent on the stream of the next Run
+	defer c.drainSendQueue()
+	// handlers must have returned before we close the channels
+	c.dispatcher.start()
+	defer c.dispatcher.stop()
+
+	retries := 0
+	for {
+		c.setConnectionState(StateConnecting)
+		connected, err := c.runStream(ctx, arbitrationCh, messageCh)
+		c.setConnectionState(StateDisconnected)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if c.DisableReconnect || !isRetryableStreamError(err) {
+			return err
+		}
+		if connected {
+			retries = 0
+		}
+		// ReconnectBackoff may also have been set after NewClient
+		backoff := c.ReconnectBackoff.withDefaults(DefaultBackoff)
+		if backoff.exhausted(retries) {
+			log.Errorf("Giving up on stream after %d attempts", retries)
+			return err
+		}
+		delay := backoff.delay(retries)
+		retries++
+		log.Warnf("Stream lost (%v), reconnecting in %v", err, delay)
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		}
+	}
+}
+
+func closeStreamChannels(arbitrationCh chan<- bool, messageCh chan<- *p4_v1.StreamMessageResponse) {
+	if messageCh != nil {
+		close(messageCh)
+	}
+	if arbitrationCh != nil {
+		close(arbitrationCh)
+	}
+}
+
+// runStream runs a single StreamChannel session until ctx is cancelled or the
+// stream fails. connected is true if the MasterArbitrationUpdate could be sent.
+// It only returns once the receive goroutine has exited.
+func (c *Client) runStream(
+	ctx context.Context,
+	arbitrationCh chan<- bool,
+	messageCh chan<- *p4_v1.StreamMessageResponse,
+) (connected bool, err error) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	// cancelling streamCtx unblocks stream.Recv in the receive goroutine
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := c.StreamChannel(streamCtx)
 	if err != nil {
-		return fmt.Errorf("cannot establish stream: %v", err)
+		return false, &StreamChannelError{Op: "open", Err: err}
 	}
 
 	defer stream.CloseSend()
+	c.digests.reset()
+
+	if err := stream.Send(&p4_v1.StreamMessageRequest{
+		Update: &p4_v1.StreamMessageRequest_Arbitration{Arbitration: &p4_v1.MasterArbitrationUpdate{
+			DeviceId:   c.deviceID,
+			Role:       c.role(),
+			ElectionId: &c.electionID,
+		}},
+	}); err != nil {
+		return false, &StreamChannelError{Op: "send", Err: err}
+	}
+	c.setConnectionState(StateConnected)
 
+	recvErrCh := make(chan error, 1)
+	wg.Add(1)
 	go func() {
+		defer wg.Done()
 		for {
 			in, err := stream.Recv()
-			if err == io.EOF {
-				// TODO: should reconnect
-				return
-			}
 			if err != nil {
-				log.Fatalf("Failed to receive a stream message : %v", err)
+				recvErrCh <- &StreamChannelError{Op: "receive", Err: err}
+				return
 			}
-			arbitration, ok := in.Update.(*p4_v1.StreamMessageResponse_Arbitration)
-			if !ok {
-				messageCh <- in
+			digestList := in.GetDigest()
+			if digestList != nil && !c.receiveDigestList(streamCtx, digestList) {
 				continue
 			}
-			if arbitration.Arbitration.Status.Code != int32(code.Code_OK) {
-				if arbitrationCh != nil {
-					arbitrationCh <- false
+			if streamError := in.GetError(); streamError != nil {
+				c.streamErrors.match(NewStreamError(streamError))
+			}
+			// update the arbitration state before any handler can query it
+			isPrimary := false
+			if arbitration := in.GetArbitration(); arbitration != nil {
+				isPrimary = c.setArbitration(arbitration)
+			}
+			dispatched := c.dispatcher.dispatch(streamCtx, in)
+			if in.GetArbitration() == nil {
+				if messageCh != nil {
+					select {
+					case messageCh <- in:
+					case <-streamCtx.Done():
+						return
+					}
+				}
+				if digestList != nil && !dispatched {
+					c.handledDigestList(streamCtx, digestList)
 				}
+				continue
+			}
+			if isPrimary {
+				c.setConnectionState(StatePrimary)
 			} else {
-				if arbitrationCh != nil {
-					arbitrationCh <- true
+				c.setConnectionState(StateBackup)
+			}
+			if arbitrationCh != nil {
+				select {
+				case arbitrationCh <- isPrimary:
+				case <-streamCtx.Done():
+					return
 				}
 			}
 		}
 	}()
 
-	stream.Send(&p4_v1.StreamMessageRequest{
-		Update: &p4_v1.StreamMessageRequest_Arbitration{Arbitration: &p4_v1.MasterArbitrationUpdate{
-			DeviceId:   c.deviceID,
-			ElectionId: &c.electionID,
-		}},
-	})
-
 	for {
 		select {
-		case m := <-c.streamSendCh:
-			stream.Send(m)
-		case <-stopCh:
-			return nil
+		case req := <-c.streamSendCh:
+			c.sendStreamRequest(stream, req)
+		case err := <-recvErrCh:
+			return true, err
+		case <-ctx.Done():
+			return true, nil
 		}
 	}
 }
 
-func (c *Client) WriteUpdate(ctx context.Context, update *p4_v1.Update) error {
-	req := &p4_v1.WriteRequest{
-		DeviceId:   c.deviceID,
-		ElectionId: &c.electionID,
-		Updates:    []*p4_v1.Update{update},
-	}
-	_, err := c.Write(ctx, req)
-	return err
+func (c *Client) WriteUpdate(ctx context.Context, update *p4_v1.Update, optionsModifierFns ...func(*WriteOptions)) error {
+	return c.WriteManyUpdate(ctx, []*p4_v1.Update{update}, optionsModifierFns...)
 }
 
-func (c *Client) WriteManyUpdate(ctx context.Context, updates []*p4_v1.Update) error {
+// WriteManyUpdate sends all updates in a single WriteRequest. If the server
+// reports the outcome of each update, the error is a *WriteError. If the
+// server does not support the requested atomicity, the error is an
+// *UnsupportedAtomicityError. The write is retried according to
+// WriteRetryPolicy. With CONTINUE_ON_ERROR, a WriteRequest larger than
+// MaxRequestBytes is split into several requests, sent in order.
+func (c *Client) WriteManyUpdate(ctx context.Context, updates []*p4_v1.Update, optionsModifierFns ...func(*WriteOptions)) error {
+	options := c.writeOptions(optionsModifierFns...)
 	req := &p4_v1.WriteRequest{
 		DeviceId:   c.deviceID,
 		ElectionId: &c.electionID,
+		Role:       c.RoleName,
 		Updates:    updates,
+		Atomicity:  p4_v1.WriteRequest_Atomicity(options.Atomicity),
+	}
+	write := func(req *p4_v1.WriteRequest) error {
+		return c.retryWrite(ctx, req.Updates, func() error {
+			// checked for every attempt, as the client is not primary while
+			// the stream reconnects
+			if err := c.checkPrimary(); err != nil {
+				return err
+			}
+			if _, err := c.Write(ctx, req); err != nil {
+				if isUnsupportedAtomicity(options.Atomicity, err) {
+					return &UnsupportedAtomicityError{Atomicity: options.Atomicity, Err: err}
+				}
+				return c.newWriteError(err, req.Updates)
+			}
+			return nil
+		})
+	}
+	requests := c.splitWriteRequest(req)
+	if len(requests) == 1 {
+		return write(req)
 	}
-	_, err := c.Write(ctx, req)
-	return err
+	return c.writeChunks(requests, write)
 }
 
 func (c *Client) ReadEntitySingle(ctx context.Context, entity *p4_v1.Entity) (*p4_v1.Entity, error) {
-	req := &p4_v1.ReadRequest{
-		DeviceId: c.deviceID,
-		Entities: []*p4_v1.Entity{entity},
-	}
+	req := c.newReadRequests([]*p4_v1.Entity{entity})[0]
 	stream, err := c.Read(ctx, req)
 	if err != nil {
 		return nil, err
@@ -169,29 +649,19 @@ func (c *Client) ReadEntitySingle(ctx context.Context, entity *p4_v1.Entity) (*p
 }
 
 // ReadEntityWildcard will block and send all read entities on readEntityCh. It will close the
-// channel when the RPC completes and return any error that may have occurred.
+// channel when the RPC completes and return any error that may have occurred. If the caller stops
+// receiving from readEntityCh, it must cancel ctx; see ReadEntityIterator for a pull-based API.
 func (c *Client) ReadEntityWildcard(ctx context.Context, entity *p4_v1.Entity, readEntityCh chan<- *p4_v1.Entity) error {
 	defer close(readEntityCh)
 
-	req := &p4_v1.ReadRequest{
-		DeviceId: c.deviceID,
-		Entities: []*p4_v1.Entity{entity},
-	}
-	stream, err := c.Read(ctx, req)
-	if err != nil {
-		return err
-	}
-	for {
-		rep, err := stream.Recv()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return err
-		}
-		for _, e := range rep.Entities {
-			readEntityCh <- e
+	it := c.ReadEntityIterator(ctx, entity)
+	defer it.Close()
+	for it.Next() {
+		select {
+		case readEntityCh <- it.Entity():
+		case <-ctx.Done():
+			return ctx.Err()
 		}
 	}
-	return nil
+	return it.Err()
 }
@@ -2,8 +2,20 @@ package client
 
 import (
 	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+
+	code "google.golang.org/genproto/googleapis/rpc/code"
+	"google.golang.org/genproto/googleapis/rpc/status"
 
 	p4_config_v1 "github.com/p4lang/p4runtime/go/p4/config/v1"
 	p4_v1 "github.com/p4lang/p4runtime/go/p4/v1"
@@ -78,13 +90,480 @@ func (c *fakeP4RuntimeReadClient) Recv() (*p4_v1.ReadResponse, error) {
 	return c.recvFn()
 }
 
+type fakeP4RuntimeStreamChannelClient struct {
+	grpc.ClientStream
+	sendFn func(*p4_v1.StreamMessageRequest) error
+	recvFn func() (*p4_v1.StreamMessageResponse, error)
+}
+
+// fakeP4RuntimeStreamChannelClient implements the p4_v1.P4Runtime_StreamChannelClient interface
+var _ p4_v1.P4Runtime_StreamChannelClient = &fakeP4RuntimeStreamChannelClient{}
+
+func (c *fakeP4RuntimeStreamChannelClient) Send(m *p4_v1.StreamMessageRequest) error {
+	if c.sendFn == nil {
+		return nil
+	}
+	return c.sendFn(m)
+}
+
+func (c *fakeP4RuntimeStreamChannelClient) Recv() (*p4_v1.StreamMessageResponse, error) {
+	if c.recvFn == nil {
+		panic("No mock provided for Recv function")
+	}
+	return c.recvFn()
+}
+
+func (c *fakeP4RuntimeStreamChannelClient) CloseSend() error {
+	return nil
+}
+
 func newTestClient(p4RuntimeClient *fakeP4RuntimeClient, p4Info *p4_config_v1.P4Info) *Client {
-	return &Client{
+	c := &Client{
 		ClientOptions:   defaultClientOptions,
 		P4RuntimeClient: p4RuntimeClient,
 		deviceID:        1,
 		electionID:      p4_v1.Uint128{High: 0, Low: 1},
-		streamSendCh:    make(chan *p4_v1.StreamMessageRequest, 1000),
+		streamSendCh:    make(chan *streamRequest, defaultSendQueueSize),
 		p4Info:          p4Info,
+		digests:         newDigestTracker(),
+		streamErrors:    newStreamErrorTracker(),
+		primaryCh:       make(chan struct{}),
+	}
+	c.dispatcher = newDispatcher(c)
+	return c
+}
+
+func arbitrationResponse(c code.Code) *p4_v1.StreamMessageResponse {
+	return &p4_v1.StreamMessageResponse{
+		Update: &p4_v1.StreamMessageResponse_Arbitration{Arbitration: &p4_v1.MasterArbitrationUpdate{
+			Status: &status.Status{Code: int32(c)},
+		}},
+	}
+}
+
+// TestRunReconnect ensures that Run re-opens the StreamChannel when the server closes it, and
+// that it sends the same MasterArbitrationUpdate again.
+func TestRunReconnect(t *testing.T) {
+	var mutex sync.Mutex
+	var arbitrations []*p4_v1.MasterArbitrationUpdate
+	numStreams := 0
+	p4RtClient := &fakeP4RuntimeClient{
+		streamChannelFn: func(ctx context.Context, opts ...grpc.CallOption) (p4_v1.P4Runtime_StreamChannelClient, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			numStreams++
+			first := numStreams == 1
+			sentArbitration := false
+			return &fakeP4RuntimeStreamChannelClient{
+				sendFn: func(m *p4_v1.StreamMessageRequest) error {
+					mutex.Lock()
+					defer mutex.Unlock()
+					arbitrations = append(arbitrations, m.GetArbitration())
+					return nil
+				},
+				recvFn: func() (*p4_v1.StreamMessageResponse, error) {
+					if first {
+						return nil, io.EOF
+					}
+					if !sentArbitration {
+						sentArbitration = true
+						return arbitrationResponse(code.Code_OK), nil
+					}
+					<-ctx.Done()
+					return nil, ctx.Err()
+				},
+			}, nil
+		},
+	}
+	fakeClient := newTestClient(p4RtClient, nil)
+	fakeClient.ReconnectBackoff = Backoff{BaseDelay: time.Millisecond, Multiplier: 1, MaxDelay: time.Millisecond}
+	stateCh := make(chan ConnectionState, 100)
+	fakeClient.ConnectionStateFn = func(state ConnectionState) {
+		stateCh <- state
+	}
+
+	stopCh := make(chan struct{})
+	doneCh := make(chan error)
+	go func() {
+		doneCh <- fakeClient.Run(stopCh, nil, nil)
+	}()
+
+	var states []ConnectionState
+	timeout := time.After(1 * time.Second)
+	for len(states) == 0 || states[len(states)-1] != StatePrimary {
+		select {
+		case state := <-stateCh:
+			states = append(states, state)
+		case <-timeout:
+			require.FailNow(t, "Timeout", "client should become primary after reconnecting, states: %v", states)
+		}
+	}
+	close(stopCh)
+	assert.NoError(t, <-doneCh)
+
+	assert.Equal(t, []ConnectionState{
+		StateConnecting, StateConnected, StateDisconnected, StateConnecting, StateConnected, StatePrimary,
+	}, states)
+	mutex.Lock()
+	defer mutex.Unlock()
+	require.Len(t, arbitrations, 2)
+	for _, arbitration := range arbitrations {
+		assert.Equal(t, uint64(1), arbitration.DeviceId)
+		assert.Equal(t, uint64(1), arbitration.ElectionId.Low)
+	}
+}
+
+// TestRunReconnectDigestAck ensures that a DigestList which is still being handled when the
+// stream reconnects is not acked on the new stream, where its ID may identify another list.
+func TestRunReconnectDigestAck(t *testing.T) {
+	var mutex sync.Mutex
+	var acks []int
+	numStreams := 0
+	oldHandlingCh := make(chan struct{})
+	newDispatchedCh := make(chan struct{})
+	releaseCh := make(chan struct{})
+	p4RtClient := &fakeP4RuntimeClient{
+		streamChannelFn: func(ctx context.Context, opts ...grpc.CallOption) (p4_v1.P4Runtime_StreamChannelClient, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			numStreams++
+			stream := numStreams
+			responses := []*p4_v1.StreamMessageResponse{
+				arbitrationResponse(code.Code_OK),
+				{Update: &p4_v1.StreamMessageResponse_Digest{Digest: &p4_v1.DigestList{DigestId: 1, ListId: 1}}},
+			}
+			return &fakeP4RuntimeStreamChannelClient{
+				sendFn: func(m *p4_v1.StreamMessageRequest) error {
+					mutex.Lock()
+					defer mutex.Unlock()
+					if m.GetDigestAck() != nil {
+						acks = append(acks, stream)
+					}
+					return nil
+				},
+				recvFn: func() (*p4_v1.StreamMessageResponse, error) {
+					if len(responses) > 0 {
+						m := responses[0]
+						responses = responses[1:]
+						return m, nil
+					}
+					if stream == 1 {
+						// the switch restarts while the list is being handled
+						<-oldHandlingCh
+						return nil, io.EOF
+					}
+					close(newDispatchedCh)
+					<-ctx.Done()
+					return nil, ctx.Err()
+				},
+			}, nil
+		},
+	}
+	fakeClient := newTestClient(p4RtClient, nil)
+	fakeClient.DigestAckMode = DigestAckAfterHandler
+	fakeClient.ReconnectBackoff = Backoff{BaseDelay: time.Millisecond, Multiplier: 1, MaxDelay: time.Millisecond}
+	handled := 0
+	fakeClient.Dispatcher().OnDigestList(func(*p4_v1.DigestList) {
+		handled++
+		if handled == 1 {
+			close(oldHandlingCh)
+			<-releaseCh
+		}
+	})
+
+	stopCh := make(chan struct{})
+	doneCh := make(chan error)
+	go func() {
+		doneCh <- fakeClient.Run(stopCh, nil, nil)
+	}()
+	<-newDispatchedCh
+	close(releaseCh)
+	require.Eventually(t, func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return len(acks) > 0
+	}, time.Second, time.Millisecond)
+	// leave time for an unexpected ack
+	time.Sleep(50 * time.Millisecond)
+	close(stopCh)
+	assert.NoError(t, <-doneCh)
+
+	assert.Equal(t, 2, handled)
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Equal(t, []int{2}, acks, "only the list of the second stream should be acked")
+}
+
+// TestRunReceiveError ensures that a non-transient receive error ends Run with an error which
+// preserves the gRPC status code, and that Run closes the channels it was given.
+func TestRunReceiveError(t *testing.T) {
+	p4RtClient := &fakeP4RuntimeClient{
+		streamChannelFn: func(ctx context.Context, opts ...grpc.CallOption) (p4_v1.P4Runtime_StreamChannelClient, error) {
+			return &fakeP4RuntimeStreamChannelClient{
+				recvFn: func() (*p4_v1.StreamMessageResponse, error) {
+					return nil, grpcstatus.Error(codes.PermissionDenied, "not allowed")
+				},
+			}, nil
+		},
+	}
+	fakeClient := newTestClient(p4RtClient, nil)
+	arbitrationCh := make(chan bool)
+	messageCh := make(chan *p4_v1.StreamMessageResponse)
+
+	doneCh := make(chan error)
+	go func() {
+		doneCh <- fakeClient.Run(make(chan struct{}), arbitrationCh, messageCh)
+	}()
+
+	var err error
+	select {
+	case err = <-doneCh:
+	case <-time.After(1 * time.Second):
+		require.FailNow(t, "Timeout", "Run should return when the stream fails")
+	}
+
+	var streamErr *StreamChannelError
+	require.True(t, errors.As(err, &streamErr))
+	assert.Equal(t, "receive", streamErr.Op)
+	assert.Equal(t, codes.PermissionDenied, grpcstatus.Code(err))
+	_, ok := <-messageCh
+	assert.False(t, ok, "messageCh should be closed")
+	_, ok = <-arbitrationCh
+	assert.False(t, ok, "arbitrationCh should be closed")
+}
+
+// TestRole ensures that the role of the client is included in the MasterArbitrationUpdate and in
+// Write requests, and that the primary status for the role is tracked.
+func TestRole(t *testing.T) {
+	arbitrationCh := make(chan *p4_v1.MasterArbitrationUpdate, 1)
+	p4RtClient := &fakeP4RuntimeClient{
+		streamChannelFn: func(ctx context.Context, opts ...grpc.CallOption) (p4_v1.P4Runtime_StreamChannelClient, error) {
+			sentArbitration := false
+			return &fakeP4RuntimeStreamChannelClient{
+				sendFn: func(m *p4_v1.StreamMessageRequest) error {
+					arbitrationCh <- m.GetArbitration()
+					return nil
+				},
+				recvFn: func() (*p4_v1.StreamMessageResponse, error) {
+					if !sentArbitration {
+						sentArbitration = true
+						return arbitrationResponse(code.Code_OK), nil
+					}
+					<-ctx.Done()
+					return nil, ctx.Err()
+				},
+			}, nil
+		},
+		writeFn: func(ctx context.Context, in *p4_v1.WriteRequest, opts ...grpc.CallOption) (*p4_v1.WriteResponse, error) {
+			assert.Equal(t, "acl", in.Role)
+			return &p4_v1.WriteResponse{}, nil
+		},
+	}
+	fakeClient := newTestClient(p4RtClient, nil)
+	WithRole("acl", nil)(&fakeClient.ClientOptions)
+	stateCh := make(chan ConnectionState, 100)
+	fakeClient.ConnectionStateFn = func(state ConnectionState) {
+		stateCh <- state
+	}
+	assert.Equal(t, RoleStatus{Role: "acl", Primary: false}, fakeClient.RoleStatus())
+
+	stopCh := make(chan struct{})
+	doneCh := make(chan error)
+	go func() {
+		doneCh <- fakeClient.Run(stopCh, nil, nil)
+	}()
+	arbitration := <-arbitrationCh
+	assert.Equal(t, "acl", arbitration.GetRole().GetName())
+	for state := range stateCh {
+		if state == StatePrimary {
+			break
+		}
+	}
+	assert.Equal(t, RoleStatus{Role: "acl", Primary: true}, fakeClient.RoleStatus())
+	assert.NoError(t, fakeClient.WriteUpdate(context.Background(), &p4_v1.Update{}))
+
+	close(stopCh)
+	assert.NoError(t, <-doneCh)
+	assert.False(t, fakeClient.RoleStatus().Primary)
+}
+
+// TestWaitForPrimary ensures that writes fail fast while the client is a backup, and that
+// WaitForPrimary returns once the server reports that the client has become the primary.
+func TestWaitForPrimary(t *testing.T) {
+	recvCh := make(chan *p4_v1.StreamMessageResponse)
+	p4RtClient := &fakeP4RuntimeClient{
+		streamChannelFn: func(ctx context.Context, opts ...grpc.CallOption) (p4_v1.P4Runtime_StreamChannelClient, error) {
+			return &fakeP4RuntimeStreamChannelClient{
+				recvFn: func() (*p4_v1.StreamMessageResponse, error) {
+					select {
+					case m := <-recvCh:
+						return m, nil
+					case <-ctx.Done():
+						return nil, ctx.Err()
+					}
+				},
+			}, nil
+		},
+		writeFn: func(ctx context.Context, in *p4_v1.WriteRequest, opts ...grpc.CallOption) (*p4_v1.WriteResponse, error) {
+			return &p4_v1.WriteResponse{}, nil
+		},
+	}
+	fakeClient := newTestClient(p4RtClient, nil)
+	assert.False(t, fakeClient.IsPrimary())
+	assert.NoError(t, fakeClient.WriteUpdate(context.Background(), &p4_v1.Update{}), "writes are allowed without Run")
+
+	stopCh := make(chan struct{})
+	doneCh := make(chan error)
+	go func() {
+		doneCh <- fakeClient.Run(stopCh, nil, nil)
+	}()
+
+	backup := arbitrationResponse(code.Code_ALREADY_EXISTS)
+	backup.GetArbitration().ElectionId = &p4_v1.Uint128{High: 0, Low: 10}
+	recvCh <- backup
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, fakeClient.WaitForPrimary(ctx))
+	assert.False(t, fakeClient.IsPrimary())
+	assert.Equal(t, uint64(10), fakeClient.CurrentPrimaryElectionID().GetLow())
+	err := fakeClient.WriteUpdate(context.Background(), &p4_v1.Update{})
+	assert.True(t, errors.Is(err, ErrNotPrimary))
+	assert.Equal(t, codes.PermissionDenied, grpcstatus.Code(err))
+
+	primary := arbitrationResponse(code.Code_OK)
+	primary.GetArbitration().ElectionId = &p4_v1.Uint128{High: 0, Low: 1}
+	recvCh <- primary
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	require.NoError(t, fakeClient.WaitForPrimary(ctx))
+	assert.True(t, fakeClient.IsPrimary())
+	assert.Equal(t, uint64(1), fakeClient.CurrentPrimaryElectionID().GetLow())
+	assert.NoError(t, fakeClient.WriteUpdate(context.Background(), &p4_v1.Update{}))
+
+	close(stopCh)
+	assert.NoError(t, <-doneCh)
+	assert.False(t, fakeClient.IsPrimary())
+	assert.Nil(t, fakeClient.CurrentPrimaryElectionID())
+}
+
+// TestRunContextClose ensures that Close stops RunContext and waits for it to return, and that
+// messages which could not be sent are reported as such.
+func TestRunContextClose(t *testing.T) {
+	p4RtClient := &fakeP4RuntimeClient{
+		streamChannelFn: func(ctx context.Context, opts ...grpc.CallOption) (p4_v1.P4Runtime_StreamChannelClient, error) {
+			return nil, grpcstatus.Error(codes.Unavailable, "switch is down")
+		},
+	}
+	fakeClient := newTestClient(p4RtClient, newPacketMetadataP4Info())
+	fakeClient.ReconnectBackoff = Backoff{BaseDelay: time.Hour, MaxDelay: time.Hour}
+	stateCh := make(chan ConnectionState, 100)
+	fakeClient.ConnectionStateFn = func(state ConnectionState) {
+		stateCh <- state
+	}
+
+	result, err := fakeClient.SendPacketOutAsync(context.Background(), []byte{'\x01'}, nil)
+	require.NoError(t, err)
+
+	doneCh := make(chan error, 1)
+	go func() {
+		doneCh <- fakeClient.RunContext(context.Background(), nil, nil)
+	}()
+	for state := range stateCh {
+		if state == StateDisconnected {
+			break
+		}
+	}
+	messageCh := make(chan *p4_v1.StreamMessageResponse)
+	assert.Equal(t, errAlreadyRunning, fakeClient.RunContext(context.Background(), nil, messageCh))
+	select {
+	case <-messageCh:
+		require.FailNow(t, "messageCh should not be closed when RunContext is already running")
+	default:
+	}
+
+	require.NoError(t, fakeClient.Close())
+	select {
+	case err := <-doneCh:
+		assert.NoError(t, err)
+	default:
+		require.FailNow(t, "RunContext should have returned when Close returns")
+	}
+	assert.Equal(t, ErrStreamClosed, result.Err())
+	assert.Empty(t, fakeClient.streamSendCh)
+	assert.NoError(t, fakeClient.Close())
+}
+
+// TestRunContextCloseDigestAck ensures that RunContext returns when a handler acks a DigestList
+// while the send queue is full, and that the ack is not left in the queue for the next Run.
+func TestRunContextCloseDigestAck(t *testing.T) {
+	p4RtClient := &fakeP4RuntimeClient{
+		streamChannelFn: func(ctx context.Context, opts ...grpc.CallOption) (p4_v1.P4Runtime_StreamChannelClient, error) {
+			responses := []*p4_v1.StreamMessageResponse{
+				arbitrationResponse(code.Code_OK),
+				{Update: &p4_v1.StreamMessageResponse_Digest{Digest: &p4_v1.DigestList{DigestId: 1, ListId: 1}}},
+			}
+			return &fakeP4RuntimeStreamChannelClient{
+				sendFn: func(m *p4_v1.StreamMessageRequest) error {
+					if m.GetArbitration() != nil {
+						return nil
+					}
+					// the switch is not reading from the stream
+					<-ctx.Done()
+					return ctx.Err()
+				},
+				recvFn: func() (*p4_v1.StreamMessageResponse, error) {
+					if len(responses) > 0 {
+						m := responses[0]
+						responses = responses[1:]
+						return m, nil
+					}
+					<-ctx.Done()
+					return nil, ctx.Err()
+				},
+			}, nil
+		},
+	}
+	fakeClient := newTestClient(p4RtClient, newPacketMetadataP4Info())
+	fakeClient.DigestAckMode = DigestAckAfterHandler
+	fakeClient.streamSendCh = make(chan *streamRequest, 1)
+	disconnectedCh := make(chan struct{}, 1)
+	fakeClient.ConnectionStateFn = func(state ConnectionState) {
+		if state == StateDisconnected {
+			disconnectedCh <- struct{}{}
+		}
+	}
+
+	handlingCh := make(chan struct{})
+	releaseCh := make(chan struct{})
+	fakeClient.Dispatcher().OnDigestList(func(*p4_v1.DigestList) {
+		close(handlingCh)
+		<-releaseCh
+	})
+	doneCh := make(chan error, 1)
+	go func() {
+		doneCh <- fakeClient.RunContext(context.Background(), nil, nil)
+	}()
+	<-handlingCh
+	// the first message is stuck in Send, the second one fills the queue
+	for i := 0; i < 2; i++ {
+		_, err := fakeClient.SendPacketOutAsync(context.Background(), []byte{'\x01'}, nil)
+		require.NoError(t, err)
+	}
+
+	closeCh := make(chan error, 1)
+	go func() {
+		closeCh <- fakeClient.Close()
+	}()
+	// let RunContext stop while the handler is still running
+	<-disconnectedCh
+	time.Sleep(50 * time.Millisecond)
+	close(releaseCh)
+	select {
+	case err := <-closeCh:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		require.FailNow(t, "Close should return when a handler is blocked on the send queue")
 	}
+	assert.NoError(t, <-doneCh)
+	assert.Empty(t, fakeClient.streamSendCh)
 }
@@ -3,15 +3,10 @@ package client
 import (
 	"context"
 	"fmt"
-	"sync"
 
 	p4_v1 "github.com/p4lang/p4runtime/go/p4/v1"
 )
 
-const (
-	counterWildcardReadChSize = 100
-)
-
 func (c *Client) ModifyCounterEntry(ctx context.Context, counter string, index int64, data *p4_v1.CounterData) error {
 	counterID := c.counterId(counter)
 	entry := &p4_v1.CounterEntry{
@@ -77,33 +72,19 @@ func (c *Client) ReadCounterEntryWildcard(ctx context.Context, counter string) (
 		CounterId: p4Counter.Preamble.Id,
 	}
 	out := make([]*p4_v1.CounterData, 0, p4Counter.Size)
-	readEntityCh := make(chan *p4_v1.Entity, counterWildcardReadChSize)
-	var wg sync.WaitGroup
-	var err error
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		for readEntity := range readEntityCh {
-			readEntry := readEntity.GetCounterEntry()
-			if readEntry != nil {
-				out = append(out, readEntry.Data)
-			} else if err == nil {
-				// only set the error if this is the first error we encounter
-				// dp not stop reading from the channel, as doing so would cause
-				// ReadEntityWildcard to block indefinitely
-				err = fmt.Errorf("server returned an entity which is not a counter entry!")
-			}
-		}
-	}()
-	if err := c.ReadEntityWildcard(ctx, &p4_v1.Entity{
+	it := c.ReadEntityIterator(ctx, &p4_v1.Entity{
 		Entity: &p4_v1.Entity_CounterEntry{CounterEntry: entry},
-	}, readEntityCh); err != nil {
-		// 原样返回err,以便后续可以以GRPC的错误进行处理
-		// return nil, fmt.Errorf("error when reading counter entries: %v", err)
-		return nil, err
+	})
+	defer it.Close()
+	for it.Next() {
+		readEntry := it.Entity().GetCounterEntry()
+		if readEntry == nil {
+			return nil, fmt.Errorf("server returned an entity which is not a counter entry!")
+		}
+		out = append(out, readEntry.Data)
 	}
-	wg.Wait()
-	if err != nil {
+	// 原样返回err,以便后续可以以GRPC的错误进行处理
+	if err := it.Err(); err != nil {
 		return nil, err
 	}
 	return out, nil
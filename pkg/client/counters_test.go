@@ -20,8 +20,8 @@ func TestReadCounterEntryWildcard_BadEntity(t *testing.T) {
 	counterName := "testCounter"
 	counterId := uint32(100)
 	count := 0
-	// we need the number of entities following the "bad" entity to exceed the channel capacity
-	numEntities := counterWildcardReadChSize + 10
+	// many entities follow the "bad" entity, the read must not wait for them to be consumed
+	numEntities := 110
 	p4RtReadClient := &fakeP4RuntimeReadClient{
 		recvFn: func() (*p4_v1.ReadResponse, error) {
 			if count > 0 {
@@ -0,0 +1,99 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	p4_config_v1 "github.com/p4lang/p4runtime/go/p4/config/v1"
+	p4_v1 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+// checkDefaultAction returns the P4Info table, after checking that its
+// default action can be changed, to action if it is not empty.
+func (c *Client) checkDefaultAction(table string, action string) (*p4_config_v1.Table, error) {
+	p4Table := c.findTable(table)
+	if p4Table == nil {
+		return nil, fmt.Errorf("table %s not found", table)
+	}
+	if p4Table.ConstDefaultActionId != 0 {
+		constAction := c.getActionById(p4Table.ConstDefaultActionId).GetPreamble().GetName()
+		return nil, fmt.Errorf("table %s has a const default action (%s) which cannot be changed", table, constAction)
+	}
+	if action == "" {
+		return p4Table, nil
+	}
+	actionID := c.actionId(action)
+	if actionID == invalidID {
+		return nil, fmt.Errorf("action %s not found", action)
+	}
+	for _, ref := range p4Table.ActionRefs {
+		if ref.Id != actionID {
+			continue
+		}
+		if ref.Scope == p4_config_v1.ActionRef_TABLE_ONLY {
+			return nil, fmt.Errorf("action %s cannot be the default action of table %s (@tableonly)", action, table)
+		}
+		return p4Table, nil
+	}
+	return nil, fmt.Errorf("action %s is not an action of table %s", action, table)
+}
+
+func (c *Client) modifyDefaultEntry(ctx context.Context, tableID uint32, action *p4_v1.TableAction) error {
+	// the default entry always exists, so it can only be modified
+	update := &p4_v1.Update{
+		Type: p4_v1.Update_MODIFY,
+		Entity: &p4_v1.Entity{
+			Entity: &p4_v1.Entity_TableEntry{TableEntry: &p4_v1.TableEntry{
+				TableId:         tableID,
+				IsDefaultAction: true,
+				Action:          action,
+			}},
+		},
+	}
+	return c.WriteUpdate(ctx, update)
+}
+
+// SetDefaultAction sets the action executed by table on a miss. It fails
+// without contacting the server if the default action of table is const, or if
+// action is not allowed as a default action (@tableonly).
+func (c *Client) SetDefaultAction(ctx context.Context, table string, action string, params [][]byte) error {
+	p4Table, err := c.checkDefaultAction(table, action)
+	if err != nil {
+		return err
+	}
+	return c.modifyDefaultEntry(ctx, p4Table.Preamble.Id, c.NewTableActionDirect(action, params))
+}
+
+// ResetDefaultAction restores the default action of table to the one from the
+// P4 program.
+func (c *Client) ResetDefaultAction(ctx context.Context, table string) error {
+	p4Table, err := c.checkDefaultAction(table, "")
+	if err != nil {
+		return err
+	}
+	return c.modifyDefaultEntry(ctx, p4Table.Preamble.Id, nil)
+}
+
+// ReadDefaultAction reads the action executed by table on a miss.
+func (c *Client) ReadDefaultAction(ctx context.Context, table string) (*p4_v1.TableAction, error) {
+	tableID := c.tableId(table)
+	if tableID == invalidID {
+		return nil, fmt.Errorf("table %s not found", table)
+	}
+	entry := &p4_v1.TableEntry{
+		TableId:         tableID,
+		IsDefaultAction: true,
+	}
+	readEntity, err := c.ReadEntitySingle(ctx, &p4_v1.Entity{
+		Entity: &p4_v1.Entity_TableEntry{TableEntry: entry},
+	})
+	if err != nil {
+		// 原样返回err,以便后续可以以GRPC的错误进行处理
+		return nil, err
+	}
+	readEntry := readEntity.GetTableEntry()
+	if readEntry == nil {
+		return nil, fmt.Errorf("server returned an entity but it is not a table entry! ")
+	}
+	return readEntry.Action, nil
+}
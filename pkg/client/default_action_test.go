@@ -0,0 +1,98 @@
+package client
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	//nolint:staticcheck // SA1019 To be resolved later
+	//lint:ignore SA1019 This line added for support golint version of VSC
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+
+	p4_config_v1 "github.com/p4lang/p4runtime/go/p4/config/v1"
+	p4_v1 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+func TestDefaultAction(t *testing.T) {
+	var written []*p4_v1.Update
+	var read []*p4_v1.ReadRequest
+	p4RtClient := &fakeP4RuntimeClient{
+		writeFn: func(ctx context.Context, in *p4_v1.WriteRequest, opts ...grpc.CallOption) (*p4_v1.WriteResponse, error) {
+			written = append(written, in.Updates...)
+			return &p4_v1.WriteResponse{}, nil
+		},
+		readFn: func(ctx context.Context, in *p4_v1.ReadRequest, opts ...grpc.CallOption) (p4_v1.P4Runtime_ReadClient, error) {
+			read = append(read, in)
+			done := false
+			return &fakeP4RuntimeReadClient{
+				recvFn: func() (*p4_v1.ReadResponse, error) {
+					if done {
+						return nil, io.EOF
+					}
+					done = true
+					entry := proto.Clone(in.Entities[0].GetTableEntry()).(*p4_v1.TableEntry)
+					entry.Action = &p4_v1.TableAction{
+						Type: &p4_v1.TableAction_Action{Action: &p4_v1.Action{ActionId: 10}},
+					}
+					return &p4_v1.ReadResponse{Entities: []*p4_v1.Entity{
+						{Entity: &p4_v1.Entity_TableEntry{TableEntry: entry}},
+					}}, nil
+				},
+			}, nil
+		},
+	}
+	p4Info := &p4_config_v1.P4Info{
+		Tables: []*p4_config_v1.Table{
+			{
+				Preamble: &p4_config_v1.Preamble{Name: "dmac", Id: 1},
+				ActionRefs: []*p4_config_v1.ActionRef{
+					{Id: 10},
+					{Id: 11, Scope: p4_config_v1.ActionRef_TABLE_ONLY},
+					{Id: 12, Scope: p4_config_v1.ActionRef_DEFAULT_ONLY},
+				},
+			},
+			{
+				Preamble:             &p4_config_v1.Preamble{Name: "smac", Id: 2},
+				ActionRefs:           []*p4_config_v1.ActionRef{{Id: 10}},
+				ConstDefaultActionId: 10,
+			},
+		},
+		Actions: []*p4_config_v1.Action{
+			{Preamble: &p4_config_v1.Preamble{Name: "drop", Id: 10}},
+			{Preamble: &p4_config_v1.Preamble{Name: "fwd", Id: 11}},
+			{Preamble: &p4_config_v1.Preamble{Name: "flood", Id: 12}},
+			{Preamble: &p4_config_v1.Preamble{Name: "learn", Id: 13}},
+		},
+	}
+	fakeClient := newTestClient(p4RtClient, p4Info)
+	ctx := context.Background()
+
+	require.NoError(t, fakeClient.SetDefaultAction(ctx, "dmac", "flood", nil))
+	require.NoError(t, fakeClient.ResetDefaultAction(ctx, "dmac"))
+	require.Len(t, written, 2)
+	for _, update := range written {
+		assert.Equal(t, p4_v1.Update_MODIFY, update.Type)
+		assert.True(t, update.Entity.GetTableEntry().IsDefaultAction)
+		assert.Empty(t, update.Entity.GetTableEntry().Match)
+	}
+	assert.Equal(t, uint32(12), written[0].Entity.GetTableEntry().GetAction().GetAction().GetActionId())
+	assert.Nil(t, written[1].Entity.GetTableEntry().Action)
+
+	action, err := fakeClient.ReadDefaultAction(ctx, "smac")
+	require.NoError(t, err)
+	require.Len(t, read, 1)
+	assert.True(t, read[0].Entities[0].GetTableEntry().IsDefaultAction)
+	assert.Equal(t, uint32(10), action.GetAction().GetActionId())
+
+	// errors detected before sending the request
+	written = nil
+	assert.EqualError(t, fakeClient.SetDefaultAction(ctx, "smac", "drop", nil), "table smac has a const default action (drop) which cannot be changed")
+	assert.EqualError(t, fakeClient.ResetDefaultAction(ctx, "smac"), "table smac has a const default action (drop) which cannot be changed")
+	assert.EqualError(t, fakeClient.SetDefaultAction(ctx, "dmac", "fwd", nil), "action fwd cannot be the default action of table dmac (@tableonly)")
+	assert.EqualError(t, fakeClient.SetDefaultAction(ctx, "dmac", "learn", nil), "action learn is not an action of table dmac")
+	assert.EqualError(t, fakeClient.SetDefaultAction(ctx, "foo", "drop", nil), "table foo not found")
+	assert.Empty(t, written)
+}
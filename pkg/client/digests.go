@@ -2,24 +2,298 @@ package client
 
 import (
 	"context"
+	"fmt"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
 
 	p4_v1 "github.com/p4lang/p4runtime/go/p4/v1"
 )
 
+const (
+	// number of acked list IDs remembered per digest, to detect duplicates
+	recentDigestListsSize = 1024
+)
+
+// DigestAckMode controls whether the client acknowledges DigestLists itself.
+type DigestAckMode int
+
+const (
+	// DigestAckManual leaves it to the caller to call AckDigestList.
+	DigestAckManual DigestAckMode = iota
+	// DigestAckAfterHandler acks each DigestList once the OnDigestList
+	// handler of the Dispatcher has returned for it. If no handler is
+	// registered, the list is acked once it has been sent on messageCh.
+	// Lists are acked even if the handler failed to process them: use
+	// DigestAckManual to ack only the lists which were processed
+	// successfully, so that the server sends the other ones again.
+	DigestAckAfterHandler
+	// DigestAckAtMostOnce acks each DigestList as soon as it is received,
+	// before it is handed to the application.
+	DigestAckAtMostOnce
+)
+
+// WithDigestAck sets the DigestAckMode and the maximum number of DigestLists
+// which can be outstanding (received but not acked) per digest. When that
+// limit is reached, new lists are discarded without being acked, and the
+// server will send them again after the digest ack_timeout_ns. 0 means no
+// limit.
+func WithDigestAck(mode DigestAckMode, maxOutstanding int) func(*ClientOptions) {
+	return func(options *ClientOptions) {
+		options.DigestAckMode = mode
+		options.MaxOutstandingDigestLists = maxOutstanding
+	}
+}
+
+type DigestAckStats struct {
+	Outstanding int    // lists received but not acked yet
+	Acked       uint64 // lists acked
+	Duplicates  uint64 // lists received again, after they were already received
+	Throttled   uint64 // lists discarded because of MaxOutstandingDigestLists
+}
+
+type digestListVerdict int
+
+const (
+	digestListDeliver digestListVerdict = iota
+	digestListOutstanding
+	digestListAcked
+	digestListThrottled
+)
+
+type digestFlow struct {
+	// outstanding lists, as they were received on the current stream
+	outstanding map[uint64]*p4_v1.DigestList
+	acked       map[uint64]struct{}
+	ackedOrder  []uint64
+	stats       DigestAckStats
+}
+
+// digestTracker keeps track of the DigestLists which have been received and
+// acked for each digest ID. generation identifies the current stream: it is
+// incremented by reset, and acks queued for an earlier stream are not sent.
+type digestTracker struct {
+	mutex      sync.Mutex
+	flows      map[uint32]*digestFlow
+	generation uint64
+}
+
+func newDigestTracker() *digestTracker {
+	return &digestTracker{
+		flows:      make(map[uint32]*digestFlow),
+		generation: 1,
+	}
+}
+
+func (t *digestTracker) flow(digestID uint32) *digestFlow {
+	flow, ok := t.flows[digestID]
+	if !ok {
+		flow = &digestFlow{
+			outstanding: make(map[uint64]*p4_v1.DigestList),
+			acked:       make(map[uint64]struct{}),
+		}
+		t.flows[digestID] = flow
+	}
+	return flow
+}
+
+func (t *digestTracker) receive(digestList *p4_v1.DigestList, maxOutstanding int) digestListVerdict {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	flow := t.flow(digestList.DigestId)
+	if _, ok := flow.outstanding[digestList.ListId]; ok {
+		flow.stats.Duplicates++
+		return digestListOutstanding
+	}
+	if _, ok := flow.acked[digestList.ListId]; ok {
+		flow.stats.Duplicates++
+		return digestListAcked
+	}
+	if maxOutstanding > 0 && len(flow.outstanding) >= maxOutstanding {
+		flow.stats.Throttled++
+		return digestListThrottled
+	}
+	flow.outstanding[digestList.ListId] = digestList
+	return digestListDeliver
+}
+
+func (t *digestTracker) ack(digestID uint32, listID uint64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	flow := t.flow(digestID)
+	if _, ok := flow.outstanding[listID]; !ok {
+		return
+	}
+	delete(flow.outstanding, listID)
+	flow.stats.Acked++
+	flow.acked[listID] = struct{}{}
+	flow.ackedOrder = append(flow.ackedOrder, listID)
+	if len(flow.ackedOrder) > recentDigestListsSize {
+		delete(flow.acked, flow.ackedOrder[0])
+		flow.ackedOrder = flow.ackedOrder[1:]
+	}
+}
+
+// release forgets about an outstanding list which was discarded before the
+// application could handle it, so that it can be delivered when the server
+// sends it again.
+func (t *digestTracker) release(digestID uint32, listID uint64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	delete(t.flow(digestID).outstanding, listID)
+}
+
+// delivered returns the current generation if digestList is the outstanding
+// list for its ID, i.e. it was received on the current stream and not acked
+// yet, and 0 otherwise.
+func (t *digestTracker) delivered(digestList *p4_v1.DigestList) uint64 {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	flow, ok := t.flows[digestList.DigestId]
+	if !ok || flow.outstanding[digestList.ListId] != digestList {
+		return 0
+	}
+	return t.generation
+}
+
+// releaseDelivered is like release, but only if digestList was received on
+// the current stream.
+func (t *digestTracker) releaseDelivered(digestList *p4_v1.DigestList) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	flow := t.flow(digestList.DigestId)
+	if flow.outstanding[digestList.ListId] == digestList {
+		delete(flow.outstanding, digestList.ListId)
+	}
+}
+
+// stale returns true if generation is the one of an earlier stream.
+func (t *digestTracker) stale(generation uint64) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return generation != 0 && generation != t.generation
+}
+
+// reset forgets about the lists of the previous stream: list IDs are only
+// unique within a stream, and the server starts over after a restart. The
+// counters are kept.
+func (t *digestTracker) reset() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.generation++
+	for _, flow := range t.flows {
+		flow.outstanding = make(map[uint64]*p4_v1.DigestList)
+		flow.acked = make(map[uint64]struct{})
+		flow.ackedOrder = nil
+	}
+}
+
+func (t *digestTracker) stats(digestID uint32) DigestAckStats {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	flow, ok := t.flows[digestID]
+	if !ok {
+		return DigestAckStats{}
+	}
+	stats := flow.stats
+	stats.Outstanding = len(flow.outstanding)
+	return stats
+}
+
+// DigestAckStats returns the acknowledgement counters for a digest. They are
+// not maintained in DigestAckManual mode unless MaxOutstandingDigestLists is
+// set.
+func (c *Client) DigestAckStats(digest string) DigestAckStats {
+	return c.digests.stats(c.digestId(digest))
+}
+
+// receiveDigestList is called by Run for every DigestList, and returns false
+// if the list should not be delivered to the application. In DigestAckManual
+// mode, lists are only tracked if MaxOutstandingDigestLists is set.
+func (c *Client) receiveDigestList(ctx context.Context, digestList *p4_v1.DigestList) bool {
+	if c.DigestAckMode == DigestAckManual && c.MaxOutstandingDigestLists == 0 {
+		return true
+	}
+	switch c.digests.receive(digestList, c.MaxOutstandingDigestLists) {
+	case digestListOutstanding:
+		// still being handled by the application
+		return c.DigestAckMode == DigestAckManual
+	case digestListAcked:
+		if c.DigestAckMode == DigestAckManual {
+			return true
+		}
+		// the server did not get our ack
+		c.autoAckDigestList(ctx, digestList)
+		return false
+	case digestListThrottled:
+		log.Debugf("Too many outstanding lists for digest %d, discarding list %d", digestList.DigestId, digestList.ListId)
+		return false
+	}
+	if c.DigestAckMode == DigestAckAtMostOnce {
+		c.autoAckDigestList(ctx, digestList)
+	}
+	return true
+}
+
+// handledDigestList is called once the application is done with a DigestList.
+// The handlers survive a reconnection, but a list received on an earlier
+// stream must not be acked: the server may have reused its ID for another
+// list.
+func (c *Client) handledDigestList(ctx context.Context, digestList *p4_v1.DigestList) {
+	if c.DigestAckMode != DigestAckAfterHandler {
+		return
+	}
+	generation := c.digests.delivered(digestList)
+	if generation == 0 {
+		log.Debugf("Not acking list %d for digest %d, which was received on a previous stream", digestList.ListId, digestList.DigestId)
+		return
+	}
+	if _, err := c.sendDigestListAck(ctx, digestList, generation); err != nil {
+		log.Errorf("Cannot ack list %d for digest %d: %v", digestList.ListId, digestList.DigestId, err)
+	}
+}
+
+// autoAckDigestList does not wait for the ack to be sent, as it is called by
+// Run and by the handlers. If the ack is lost, the server sends the list
+// again and it is acked again.
+func (c *Client) autoAckDigestList(ctx context.Context, digestList *p4_v1.DigestList) {
+	if _, err := c.sendDigestListAck(ctx, digestList, 0); err != nil {
+		log.Errorf("Cannot ack list %d for digest %d: %v", digestList.ListId, digestList.DigestId, err)
+	}
+}
+
+// AckDigestList sends a DigestListAck on the StreamChannel, and waits until it
+// has been sent or ctx is done. Errors sent back by the server in a
+// StreamError are not reported, see AckDigestListAsync.
 func (c *Client) AckDigestList(ctx context.Context, digestList *p4_v1.DigestList) error {
-	m := &p4_v1.StreamMessageRequest{
-		Update: &p4_v1.StreamMessageRequest_DigestAck{DigestAck: &p4_v1.DigestListAck{
-			DigestId: digestList.DigestId,
-			ListId:   digestList.ListId,
-		}},
+	result, err := c.sendDigestListAck(ctx, digestList, 0)
+	if err != nil {
+		return err
+	}
+	return result.Wait(ctx)
+}
+
+// sendDigestListAck queues an ack for digestList. If generation is not 0, the
+// ack is discarded instead of being sent on a later stream.
+func (c *Client) sendDigestListAck(ctx context.Context, digestList *p4_v1.DigestList, generation uint64) (*Result, error) {
+	req := &streamRequest{
+		m: &p4_v1.StreamMessageRequest{
+			Update: &p4_v1.StreamMessageRequest_DigestAck{DigestAck: &p4_v1.DigestListAck{
+				DigestId: digestList.DigestId,
+				ListId:   digestList.ListId,
+			}},
+		},
+		result:     newResult(),
+		generation: generation,
 	}
-	select {
-	case c.streamSendCh <- m:
-		break
-	case <-ctx.Done():
-		return ctx.Err()
+	if err := c.enqueue(ctx, req); err != nil {
+		// the list must not stay outstanding forever, the server will send
+		// it again
+		c.digests.release(digestList.DigestId, digestList.ListId)
+		return nil, err
 	}
-	return nil
+	c.digests.ack(digestList.DigestId, digestList.ListId)
+	return req.result, nil
 }
 
 func (c *Client) EnableDigest(ctx context.Context, digest string, config *p4_v1.DigestEntry_Config) error {
@@ -65,3 +339,22 @@ func (c *Client) DisableDigest(ctx context.Context, digest string) error {
 	}
 	return c.WriteUpdate(ctx, update)
 }
+
+// DecodeDigestList decodes each entry of digestList with DecodeP4Data, using
+// the type_spec of the digest in the P4Info. For a digest of struct type, each
+// entry is a map[string]interface{} indexed by struct member names.
+func (c *Client) DecodeDigestList(digestList *p4_v1.DigestList) ([]interface{}, error) {
+	digest := c.findDigestById(digestList.DigestId)
+	if digest == nil {
+		return nil, fmt.Errorf("can not find digest(id=%d) in p4info", digestList.DigestId)
+	}
+	out := make([]interface{}, 0, len(digestList.Data))
+	for _, data := range digestList.Data {
+		value, err := c.DecodeP4Data(data, digest.TypeSpec)
+		if err != nil {
+			return nil, fmt.Errorf("error when decoding digest %s: %v", digest.Preamble.Name, err)
+		}
+		out = append(out, value)
+	}
+	return out, nil
+}
@@ -0,0 +1,195 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	p4_config_v1 "github.com/p4lang/p4runtime/go/p4/config/v1"
+	p4_v1 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+func bitstringTypeSpec(bitwidth int32) *p4_config_v1.P4DataTypeSpec {
+	return &p4_config_v1.P4DataTypeSpec{TypeSpec: &p4_config_v1.P4DataTypeSpec_Bitstring{
+		Bitstring: &p4_config_v1.P4BitstringLikeTypeSpec{TypeSpec: &p4_config_v1.P4BitstringLikeTypeSpec_Bit{
+			Bit: &p4_config_v1.P4BitTypeSpec{Bitwidth: bitwidth},
+		}},
+	}}
+}
+
+func bitstringData(v []byte) *p4_v1.P4Data {
+	return &p4_v1.P4Data{Data: &p4_v1.P4Data_Bitstring{Bitstring: v}}
+}
+
+func TestDecodeDigestList(t *testing.T) {
+	headerMember := func(name string) *p4_config_v1.P4HeaderTypeSpec_Member {
+		return &p4_config_v1.P4HeaderTypeSpec_Member{
+			Name:     name,
+			TypeSpec: &p4_config_v1.P4BitstringLikeTypeSpec{TypeSpec: &p4_config_v1.P4BitstringLikeTypeSpec_Bit{Bit: &p4_config_v1.P4BitTypeSpec{Bitwidth: 8}}},
+		}
+	}
+	p4Info := &p4_config_v1.P4Info{
+		Digests: []*p4_config_v1.Digest{
+			{
+				Preamble: &p4_config_v1.Preamble{Name: "digest_t", Id: 1},
+				TypeSpec: &p4_config_v1.P4DataTypeSpec{TypeSpec: &p4_config_v1.P4DataTypeSpec_Struct{
+					Struct: &p4_config_v1.P4NamedType{Name: "digest_t"},
+				}},
+			},
+		},
+		TypeInfo: &p4_config_v1.P4TypeInfo{
+			Structs: map[string]*p4_config_v1.P4StructTypeSpec{
+				"digest_t": {Members: []*p4_config_v1.P4StructTypeSpec_Member{
+					{Name: "srcAddr", TypeSpec: bitstringTypeSpec(48)},
+					{Name: "learn", TypeSpec: &p4_config_v1.P4DataTypeSpec{TypeSpec: &p4_config_v1.P4DataTypeSpec_Bool{}}},
+					{Name: "color", TypeSpec: &p4_config_v1.P4DataTypeSpec{TypeSpec: &p4_config_v1.P4DataTypeSpec_Enum{
+						Enum: &p4_config_v1.P4NamedType{Name: "color_t"},
+					}}},
+					{Name: "h", TypeSpec: &p4_config_v1.P4DataTypeSpec{TypeSpec: &p4_config_v1.P4DataTypeSpec_Header{
+						Header: &p4_config_v1.P4NamedType{Name: "h_t"},
+					}}},
+					{Name: "u", TypeSpec: &p4_config_v1.P4DataTypeSpec{TypeSpec: &p4_config_v1.P4DataTypeSpec_HeaderUnion{
+						HeaderUnion: &p4_config_v1.P4NamedType{Name: "u_t"},
+					}}},
+					{Name: "stack", TypeSpec: &p4_config_v1.P4DataTypeSpec{TypeSpec: &p4_config_v1.P4DataTypeSpec_HeaderStack{
+						HeaderStack: &p4_config_v1.P4HeaderStackTypeSpec{Header: &p4_config_v1.P4NamedType{Name: "h_t"}, Size: 2},
+					}}},
+				}},
+			},
+			Headers: map[string]*p4_config_v1.P4HeaderTypeSpec{
+				"h_t": {Members: []*p4_config_v1.P4HeaderTypeSpec_Member{headerMember("f1"), headerMember("f2")}},
+			},
+			HeaderUnions: map[string]*p4_config_v1.P4HeaderUnionTypeSpec{
+				"u_t": {Members: []*p4_config_v1.P4HeaderUnionTypeSpec_Member{
+					{Name: "h1", Header: &p4_config_v1.P4NamedType{Name: "h_t"}},
+				}},
+			},
+		},
+	}
+	validHeader := &p4_v1.P4Header{IsValid: true, Bitstrings: [][]byte{{'\x01'}, {'\x02'}}}
+	digestList := &p4_v1.DigestList{
+		DigestId: 1,
+		Data: []*p4_v1.P4Data{
+			{Data: &p4_v1.P4Data_Struct{Struct: &p4_v1.P4StructLike{Members: []*p4_v1.P4Data{
+				bitstringData([]byte{'\x00', '\x11', '\x22', '\x33', '\x44', '\x55'}),
+				{Data: &p4_v1.P4Data_Bool{Bool: true}},
+				{Data: &p4_v1.P4Data_Enum{Enum: "RED"}},
+				{Data: &p4_v1.P4Data_Header{Header: validHeader}},
+				{Data: &p4_v1.P4Data_HeaderUnion{HeaderUnion: &p4_v1.P4HeaderUnion{ValidHeaderName: "h1", ValidHeader: validHeader}}},
+				{Data: &p4_v1.P4Data_HeaderStack{HeaderStack: &p4_v1.P4HeaderStack{Entries: []*p4_v1.P4Header{validHeader, {}}}}},
+			}}}},
+		},
+	}
+	fakeClient := newTestClient(&fakeP4RuntimeClient{}, p4Info)
+
+	out, err := fakeClient.DecodeDigestList(digestList)
+	require.NoError(t, err)
+	header := map[string]interface{}{"f1": []byte{'\x01'}, "f2": []byte{'\x02'}}
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{
+			"srcAddr": []byte{'\x00', '\x11', '\x22', '\x33', '\x44', '\x55'},
+			"learn":   true,
+			"color":   "RED",
+			"h":       header,
+			"u":       map[string]interface{}{"h1": header},
+			"stack":   []interface{}{header, map[string]interface{}(nil)},
+		},
+	}, out)
+
+	digestList.Data[0].GetStruct().Members[1] = bitstringData([]byte{'\x01'})
+	_, err = fakeClient.DecodeDigestList(digestList)
+	assert.EqualError(t, err, "error when decoding digest digest_t: struct digest_t member learn: expected a bool but got *v1.P4Data_Bitstring")
+}
+
+func TestDigestAutoAck(t *testing.T) {
+	p4Info := &p4_config_v1.P4Info{
+		Digests: []*p4_config_v1.Digest{
+			{Preamble: &p4_config_v1.Preamble{Name: "digest_t", Id: 1}},
+		},
+	}
+	ctx := context.Background()
+	digestList := func(listID uint64) *p4_v1.DigestList {
+		return &p4_v1.DigestList{DigestId: 1, ListId: listID}
+	}
+	expectAck := func(c *Client, listID uint64) {
+		select {
+		case req := <-c.streamSendCh:
+			assert.Equal(t, listID, req.m.GetDigestAck().GetListId())
+		default:
+			assert.Fail(t, "expected a digest ack", "list %d", listID)
+		}
+	}
+
+	t.Run("AtMostOnce", func(t *testing.T) {
+		fakeClient := newTestClient(&fakeP4RuntimeClient{}, p4Info)
+		fakeClient.DigestAckMode = DigestAckAtMostOnce
+
+		assert.True(t, fakeClient.receiveDigestList(ctx, digestList(1)))
+		expectAck(fakeClient, 1)
+		// the server did not get the ack and sends the same list again
+		assert.False(t, fakeClient.receiveDigestList(ctx, digestList(1)))
+		expectAck(fakeClient, 1)
+		assert.Equal(t, DigestAckStats{Acked: 1, Duplicates: 1}, fakeClient.DigestAckStats("digest_t"))
+	})
+
+	t.Run("AfterHandler", func(t *testing.T) {
+		fakeClient := newTestClient(&fakeP4RuntimeClient{}, p4Info)
+		fakeClient.DigestAckMode = DigestAckAfterHandler
+		fakeClient.MaxOutstandingDigestLists = 1
+
+		list1 := digestList(1)
+		assert.True(t, fakeClient.receiveDigestList(ctx, list1))
+		assert.Empty(t, fakeClient.streamSendCh)
+		assert.False(t, fakeClient.receiveDigestList(ctx, digestList(2)), "list 2 should be throttled")
+		fakeClient.handledDigestList(ctx, list1)
+		expectAck(fakeClient, 1)
+		assert.True(t, fakeClient.receiveDigestList(ctx, digestList(2)))
+		assert.Equal(t, DigestAckStats{Outstanding: 1, Acked: 1, Throttled: 1}, fakeClient.DigestAckStats("digest_t"))
+	})
+
+	t.Run("NewStream", func(t *testing.T) {
+		fakeClient := newTestClient(&fakeP4RuntimeClient{}, p4Info)
+		fakeClient.DigestAckMode = DigestAckAtMostOnce
+
+		assert.True(t, fakeClient.receiveDigestList(ctx, digestList(1)))
+		expectAck(fakeClient, 1)
+		// the switch restarted and list IDs start over
+		fakeClient.digests.reset()
+		assert.True(t, fakeClient.receiveDigestList(ctx, digestList(1)))
+		expectAck(fakeClient, 1)
+		assert.Equal(t, DigestAckStats{Acked: 2}, fakeClient.DigestAckStats("digest_t"))
+	})
+
+	t.Run("PreviousStream", func(t *testing.T) {
+		fakeClient := newTestClient(&fakeP4RuntimeClient{}, p4Info)
+		fakeClient.DigestAckMode = DigestAckAfterHandler
+
+		oldList := digestList(1)
+		assert.True(t, fakeClient.receiveDigestList(ctx, oldList))
+		// the switch restarted and sent another list with the same ID
+		fakeClient.digests.reset()
+		newList := digestList(1)
+		assert.True(t, fakeClient.receiveDigestList(ctx, newList))
+		fakeClient.handledDigestList(ctx, oldList)
+		assert.Empty(t, fakeClient.streamSendCh)
+		assert.Equal(t, DigestAckStats{Outstanding: 1}, fakeClient.DigestAckStats("digest_t"))
+		fakeClient.handledDigestList(ctx, newList)
+		expectAck(fakeClient, 1)
+	})
+
+	t.Run("AckFailure", func(t *testing.T) {
+		fakeClient := newTestClient(&fakeP4RuntimeClient{}, p4Info)
+		fakeClient.DigestAckMode = DigestAckAfterHandler
+		fakeClient.MaxOutstandingDigestLists = 1
+		fakeClient.SendQueuePolicy = OverflowDropNewest
+		fakeClient.streamSendCh = make(chan *streamRequest)
+
+		list1 := digestList(1)
+		assert.True(t, fakeClient.receiveDigestList(ctx, list1))
+		fakeClient.handledDigestList(ctx, list1)
+		assert.Equal(t, DigestAckStats{}, fakeClient.DigestAckStats("digest_t"))
+		assert.True(t, fakeClient.receiveDigestList(ctx, digestList(2)), "list 2 should not be throttled")
+	})
+}
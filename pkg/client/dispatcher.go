@@ -0,0 +1,395 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	log "github.com/sirupsen/logrus"
+
+	p4_v1 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+const (
+	defaultHandlerQueueSize = 1000
+)
+
+// StreamEventType identifies the kind of message received on the StreamChannel.
+type StreamEventType int
+
+const (
+	PacketInEvent StreamEventType = iota
+	DigestListEvent
+	IdleTimeoutEvent
+	StreamErrorEvent
+	ArbitrationEvent
+	UnknownEvent
+)
+
+func (t StreamEventType) String() string {
+	switch t {
+	case PacketInEvent:
+		return "PacketIn"
+	case DigestListEvent:
+		return "DigestList"
+	case IdleTimeoutEvent:
+		return "IdleTimeoutNotification"
+	case StreamErrorEvent:
+		return "StreamError"
+	case ArbitrationEvent:
+		return "MasterArbitrationUpdate"
+	default:
+		return "Unknown"
+	}
+}
+
+func streamEventType(m *p4_v1.StreamMessageResponse) StreamEventType {
+	switch m.Update.(type) {
+	case *p4_v1.StreamMessageResponse_Packet:
+		return PacketInEvent
+	case *p4_v1.StreamMessageResponse_Digest:
+		return DigestListEvent
+	case *p4_v1.StreamMessageResponse_IdleTimeoutNotification:
+		return IdleTimeoutEvent
+	case *p4_v1.StreamMessageResponse_Error:
+		return StreamErrorEvent
+	case *p4_v1.StreamMessageResponse_Arbitration:
+		return ArbitrationEvent
+	default:
+		return UnknownEvent
+	}
+}
+
+// OverflowPolicy decides what happens to a new message when the queue of a
+// slow consumer is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock waits for room in the queue. This applies backpressure
+	// to the StreamChannel: no other message, including arbitration
+	// updates, is received in the meantime. It must be requested explicitly
+	// for handlers and subscribers.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest discards the oldest queued message.
+	OverflowDropOldest
+	// OverflowDropNewest discards the new message.
+	OverflowDropNewest
+)
+
+type HandlerOptions struct {
+	// Concurrency is the number of goroutines invoking the handler. With a
+	// Concurrency of 1, messages are handled one at a time, in the order in
+	// which they were received.
+	Concurrency int
+	QueueSize   int
+	// Policy defaults to OverflowDropNewest, so that a slow handler never
+	// delays the processing of arbitration updates.
+	Policy OverflowPolicy
+}
+
+var defaultHandlerOptions = HandlerOptions{
+	Concurrency: 1,
+	QueueSize:   defaultHandlerQueueSize,
+	Policy:      OverflowDropNewest,
+}
+
+func WithConcurrency(concurrency int) func(*HandlerOptions) {
+	return func(options *HandlerOptions) {
+		options.Concurrency = concurrency
+	}
+}
+
+func WithQueueSize(size int) func(*HandlerOptions) {
+	return func(options *HandlerOptions) {
+		options.QueueSize = size
+	}
+}
+
+func WithOverflowPolicy(policy OverflowPolicy) func(*HandlerOptions) {
+	return func(options *HandlerOptions) {
+		options.Policy = policy
+	}
+}
+
+type HandlerStats struct {
+	Received uint64 // messages dispatched to the handler
+	Handled  uint64 // messages for which the handler returned
+	Dropped  uint64 // messages discarded because the queue was full
+	Queued   int    // messages currently waiting in the queue
+}
+
+type handlerQueue struct {
+	options HandlerOptions
+	handle  func(*p4_v1.StreamMessageResponse)
+	// drop, if not nil, is called for every message which is discarded
+	drop func(*p4_v1.StreamMessageResponse)
+
+	mutex    sync.RWMutex
+	ch       chan *p4_v1.StreamMessageResponse
+	wg       sync.WaitGroup
+	received uint64
+	handled  uint64
+	dropped  uint64
+}
+
+func newHandlerQueue(handle func(*p4_v1.StreamMessageResponse), optionsModifierFns ...func(*HandlerOptions)) *handlerQueue {
+	options := defaultHandlerOptions
+	for _, fn := range optionsModifierFns {
+		fn(&options)
+	}
+	if options.Concurrency < 1 {
+		options.Concurrency = 1
+	}
+	if options.QueueSize < 1 {
+		options.QueueSize = 1
+	}
+	return &handlerQueue{
+		options: options,
+		handle:  handle,
+	}
+}
+
+func (q *handlerQueue) start() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	ch := make(chan *p4_v1.StreamMessageResponse, q.options.QueueSize)
+	q.ch = ch
+	for i := 0; i < q.options.Concurrency; i++ {
+		q.wg.Add(1)
+		go func() {
+			defer q.wg.Done()
+			for m := range ch {
+				q.handle(m)
+				atomic.AddUint64(&q.handled, 1)
+			}
+		}()
+	}
+}
+
+// stop lets the workers handle all queued messages and waits for them to exit.
+func (q *handlerQueue) stop() {
+	q.mutex.Lock()
+	if q.ch != nil {
+		close(q.ch)
+		q.ch = nil
+	}
+	q.mutex.Unlock()
+	q.wg.Wait()
+}
+
+func (q *handlerQueue) push(ctx context.Context, m *p4_v1.StreamMessageResponse) {
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+	if q.ch == nil {
+		return
+	}
+	atomic.AddUint64(&q.received, 1)
+	switch q.options.Policy {
+	case OverflowDropNewest:
+		select {
+		case q.ch <- m:
+		default:
+			q.dropMessage(m)
+		}
+	case OverflowDropOldest:
+		for {
+			select {
+			case q.ch <- m:
+				return
+			default:
+			}
+			select {
+			case old := <-q.ch:
+				q.dropMessage(old)
+			default:
+			}
+		}
+	default:
+		select {
+		case q.ch <- m:
+		case <-ctx.Done():
+			q.dropMessage(m)
+		}
+	}
+}
+
+func (q *handlerQueue) dropMessage(m *p4_v1.StreamMessageResponse) {
+	atomic.AddUint64(&q.dropped, 1)
+	if q.drop != nil {
+		q.drop(m)
+	}
+}
+
+func (q *handlerQueue) stats() HandlerStats {
+	q.mutex.RLock()
+	queued := len(q.ch)
+	q.mutex.RUnlock()
+	return HandlerStats{
+		Received: atomic.LoadUint64(&q.received),
+		Handled:  atomic.LoadUint64(&q.handled),
+		Dropped:  atomic.LoadUint64(&q.dropped),
+		Queued:   queued,
+	}
+}
+
+// Dispatcher invokes the registered handlers for the messages received by
+// Client.Run on the StreamChannel. Each handler has its own queue and
+// goroutines, so that a slow handler does not delay the other ones. Handlers
+// are only invoked while Run is running; when Run returns, queued messages are
+// handled before Run returns.
+type Dispatcher struct {
+	client   *Client
+	mutex    sync.Mutex
+	running  bool
+	handlers map[StreamEventType]*handlerQueue
+	// ctx is cancelled when Run stops, so that the handlers do not block on
+	// the send queue of a stream which is gone
+	ctx    context.Context
+	cancel context.CancelFunc
+	// subscriptions are independent of running, see Client.Subscribe
+	subscriptions map[*subscription]struct{}
+}
+
+func newDispatcher(client *Client) *Dispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return &Dispatcher{
+		client:        client,
+		ctx:           ctx,
+		cancel:        cancel,
+		handlers:      make(map[StreamEventType]*handlerQueue),
+		subscriptions: make(map[*subscription]struct{}),
+	}
+}
+
+// Dispatcher returns the Dispatcher used by Run for this client.
+func (c *Client) Dispatcher() *Dispatcher {
+	return c.dispatcher
+}
+
+// register sets the handler for an event type, replacing any existing one.
+func (d *Dispatcher) register(eventType StreamEventType, q *handlerQueue) {
+	d.mutex.Lock()
+	old, ok := d.handlers[eventType]
+	d.handlers[eventType] = q
+	running := d.running
+	if running {
+		q.start()
+	}
+	d.mutex.Unlock()
+	// the old handler may call the Dispatcher, so it must be waited for
+	// without holding the mutex
+	if ok && running {
+		old.stop()
+	}
+}
+
+func (d *Dispatcher) OnPacketIn(fn func(*PacketIn), optionsModifierFns ...func(*HandlerOptions)) {
+	d.register(PacketInEvent, newHandlerQueue(func(m *p4_v1.StreamMessageResponse) {
+		packetIn, err := d.client.DecodePacketIn(m.GetPacket())
+		if err != nil {
+			log.Errorf("Cannot decode PacketIn: %v", err)
+			return
+		}
+		fn(packetIn)
+	}, optionsModifierFns...))
+}
+
+// OnDigestList registers a handler for DigestLists. Depending on the
+// DigestAckMode of the client, lists may be acked automatically once fn
+// returns.
+func (d *Dispatcher) OnDigestList(fn func(*p4_v1.DigestList), optionsModifierFns ...func(*HandlerOptions)) {
+	q := newHandlerQueue(func(m *p4_v1.StreamMessageResponse) {
+		fn(m.GetDigest())
+		d.client.handledDigestList(d.handlerContext(), m.GetDigest())
+	}, optionsModifierFns...)
+	q.drop = func(m *p4_v1.StreamMessageResponse) {
+		d.client.digests.releaseDelivered(m.GetDigest())
+	}
+	d.register(DigestListEvent, q)
+}
+
+func (d *Dispatcher) OnIdleTimeout(fn func(*p4_v1.IdleTimeoutNotification), optionsModifierFns ...func(*HandlerOptions)) {
+	d.register(IdleTimeoutEvent, newHandlerQueue(func(m *p4_v1.StreamMessageResponse) {
+		fn(m.GetIdleTimeoutNotification())
+	}, optionsModifierFns...))
+}
+
+// OnStreamError registers a handler for StreamErrors. Errors caused by
+// messages sent with SendPacketOutAsync or AckDigestListAsync are also
+// reported through the corresponding Result.
+func (d *Dispatcher) OnStreamError(fn func(*StreamError), optionsModifierFns ...func(*HandlerOptions)) {
+	d.register(StreamErrorEvent, newHandlerQueue(func(m *p4_v1.StreamMessageResponse) {
+		fn(NewStreamError(m.GetError()))
+	}, optionsModifierFns...))
+}
+
+func (d *Dispatcher) OnArbitration(fn func(*p4_v1.MasterArbitrationUpdate), optionsModifierFns ...func(*HandlerOptions)) {
+	d.register(ArbitrationEvent, newHandlerQueue(func(m *p4_v1.StreamMessageResponse) {
+		fn(m.GetArbitration())
+	}, optionsModifierFns...))
+}
+
+// OnUnknown registers a handler for messages which are not covered by any of
+// the other event types.
+func (d *Dispatcher) OnUnknown(fn func(*p4_v1.StreamMessageResponse), optionsModifierFns ...func(*HandlerOptions)) {
+	d.register(UnknownEvent, newHandlerQueue(fn, optionsModifierFns...))
+}
+
+// Stats returns the counters of each registered handler.
+func (d *Dispatcher) Stats() map[StreamEventType]HandlerStats {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	out := make(map[StreamEventType]HandlerStats, len(d.handlers))
+	for eventType, q := range d.handlers {
+		out[eventType] = q.stats()
+	}
+	return out
+}
+
+// handlerContext returns the context for the operations of the handlers,
+// which is cancelled when Run stops.
+func (d *Dispatcher) handlerContext() context.Context {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.ctx
+}
+
+func (d *Dispatcher) start() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.running = true
+	d.ctx, d.cancel = context.WithCancel(context.Background())
+	for _, q := range d.handlers {
+		q.start()
+	}
+}
+
+func (d *Dispatcher) stop() {
+	d.mutex.Lock()
+	d.running = false
+	d.cancel()
+	queues := make([]*handlerQueue, 0, len(d.handlers))
+	for _, q := range d.handlers {
+		queues = append(queues, q)
+	}
+	d.mutex.Unlock()
+	// handlers may call the Dispatcher (e.g. Stats) while they are waited for
+	for _, q := range queues {
+		q.stop()
+	}
+}
+
+// dispatch returns false if there is no handler for the message. Subscribers
+// do not count as handlers.
+func (d *Dispatcher) dispatch(ctx context.Context, m *p4_v1.StreamMessageResponse) bool {
+	d.publish(ctx, m)
+	d.mutex.Lock()
+	q, ok := d.handlers[streamEventType(m)]
+	d.mutex.Unlock()
+	if !ok {
+		return false
+	}
+	q.push(ctx, m)
+	return true
+}
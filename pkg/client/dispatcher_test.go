@@ -0,0 +1,142 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	p4_v1 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+func digestListResponse(listID uint64) *p4_v1.StreamMessageResponse {
+	return &p4_v1.StreamMessageResponse{
+		Update: &p4_v1.StreamMessageResponse_Digest{Digest: &p4_v1.DigestList{ListId: listID}},
+	}
+}
+
+// TestDispatcherOverflow ensures that a slow handler drops messages according to its
+// OverflowPolicy and that the counters reflect it.
+func TestDispatcherOverflow(t *testing.T) {
+	testCases := []struct {
+		policy  OverflowPolicy
+		handled []uint64
+	}{
+		{OverflowDropNewest, []uint64{1, 2}},
+		{OverflowDropOldest, []uint64{1, 3}},
+	}
+
+	for _, tc := range testCases {
+		fakeClient := newTestClient(&fakeP4RuntimeClient{}, nil)
+		d := fakeClient.Dispatcher()
+		startedCh := make(chan struct{}, 3)
+		releaseCh := make(chan struct{})
+		var mutex sync.Mutex
+		var handled []uint64
+		d.OnDigestList(func(digestList *p4_v1.DigestList) {
+			startedCh <- struct{}{}
+			<-releaseCh
+			mutex.Lock()
+			defer mutex.Unlock()
+			handled = append(handled, digestList.ListId)
+		}, WithQueueSize(1), WithOverflowPolicy(tc.policy))
+
+		d.start()
+		ctx := context.Background()
+		d.dispatch(ctx, digestListResponse(1))
+		// wait for the handler to be busy with the first message
+		<-startedCh
+		d.dispatch(ctx, digestListResponse(2))
+		d.dispatch(ctx, digestListResponse(3))
+		close(releaseCh)
+		d.stop()
+
+		assert.Equal(t, tc.handled, handled)
+		assert.Equal(t, HandlerStats{Received: 3, Handled: 2, Dropped: 1}, d.Stats()[DigestListEvent])
+	}
+}
+
+// TestDispatcherDefaultPolicy ensures that by default a slow handler does not block the dispatch
+// of the next messages, such as arbitration updates.
+func TestDispatcherDefaultPolicy(t *testing.T) {
+	fakeClient := newTestClient(&fakeP4RuntimeClient{}, nil)
+	d := fakeClient.Dispatcher()
+	startedCh := make(chan struct{}, 1)
+	releaseCh := make(chan struct{})
+	d.OnDigestList(func(*p4_v1.DigestList) {
+		startedCh <- struct{}{}
+		<-releaseCh
+	}, WithQueueSize(1))
+	d.start()
+	d.dispatch(context.Background(), digestListResponse(1))
+	<-startedCh
+	dispatchedCh := make(chan struct{})
+	go func() {
+		defer close(dispatchedCh)
+		for listID := uint64(2); listID <= 4; listID++ {
+			d.dispatch(context.Background(), digestListResponse(listID))
+		}
+	}()
+	select {
+	case <-dispatchedCh:
+	case <-time.After(time.Second):
+		assert.FailNow(t, "dispatch should not block on a slow handler")
+	}
+	close(releaseCh)
+	d.stop()
+	assert.Equal(t, uint64(2), d.Stats()[DigestListEvent].Dropped)
+}
+
+// TestDispatcherStopReentrant ensures that handlers can call the Dispatcher while it waits for them
+// to return.
+func TestDispatcherStopReentrant(t *testing.T) {
+	fakeClient := newTestClient(&fakeP4RuntimeClient{}, nil)
+	d := fakeClient.Dispatcher()
+	startedCh := make(chan struct{})
+	releaseCh := make(chan struct{})
+	d.OnIdleTimeout(func(*p4_v1.IdleTimeoutNotification) {
+		close(startedCh)
+		<-releaseCh
+		d.Stats()
+		d.OnUnknown(func(*p4_v1.StreamMessageResponse) {})
+	})
+	d.start()
+	d.dispatch(context.Background(), &p4_v1.StreamMessageResponse{
+		Update: &p4_v1.StreamMessageResponse_IdleTimeoutNotification{},
+	})
+	<-startedCh
+
+	stoppedCh := make(chan struct{})
+	go func() {
+		defer close(stoppedCh)
+		d.stop()
+	}()
+	close(releaseCh)
+	select {
+	case <-stoppedCh:
+	case <-time.After(time.Second):
+		assert.FailNow(t, "stop should return when a handler calls the Dispatcher")
+	}
+	assert.Equal(t, uint64(1), d.Stats()[IdleTimeoutEvent].Handled)
+
+	// replacing a running handler does not wait with the mutex either
+	d.start()
+	startedCh = make(chan struct{})
+	releaseCh = make(chan struct{})
+	d.dispatch(context.Background(), &p4_v1.StreamMessageResponse{
+		Update: &p4_v1.StreamMessageResponse_IdleTimeoutNotification{},
+	})
+	<-startedCh
+	registeredCh := make(chan struct{})
+	go func() {
+		defer close(registeredCh)
+		d.OnIdleTimeout(func(*p4_v1.IdleTimeoutNotification) {})
+	}()
+	// Stats does not wait for the old handler
+	d.Stats()
+	close(releaseCh)
+	<-registeredCh
+	d.stop()
+}
@@ -0,0 +1,120 @@
+package client
+
+import (
+	"context"
+	"io"
+
+	p4_v1 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+// EntityIterator streams the entities returned by the server for Read
+// requests, keeping only one ReadResponse in memory at a time:
+//
+//	it := c.ReadEntityIterator(ctx, entity)
+//	defer it.Close()
+//	for it.Next() {
+//		process(it.Entity())
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type EntityIterator struct {
+	client   *Client
+	ctx      context.Context
+	cancel   context.CancelFunc
+	requests []*p4_v1.ReadRequest
+	stream   p4_v1.P4Runtime_ReadClient
+	entities []*p4_v1.Entity
+	entity   *p4_v1.Entity
+	err      error
+	closed   bool
+}
+
+// ReadEntityIterator returns an iterator over the entities matching entity,
+// which can include wildcards.
+func (c *Client) ReadEntityIterator(ctx context.Context, entity *p4_v1.Entity) *EntityIterator {
+	return c.newEntityIterator(ctx, []*p4_v1.Entity{entity})
+}
+
+// newEntityIterator returns an iterator over the entities matching entities,
+// which are read in order, with as many Read RPCs as needed.
+func (c *Client) newEntityIterator(ctx context.Context, entities []*p4_v1.Entity) *EntityIterator {
+	ctx, cancel := context.WithCancel(ctx)
+	return &EntityIterator{
+		client:   c,
+		ctx:      ctx,
+		cancel:   cancel,
+		requests: c.newReadRequests(entities),
+	}
+}
+
+// Next advances to the next entity, which is then returned by Entity. It
+// returns false when there are no more entities, when an error occurs (see
+// Err), or after Close.
+func (it *EntityIterator) Next() bool {
+	it.entity = nil
+	for len(it.entities) == 0 {
+		if it.closed {
+			return false
+		}
+		if it.stream == nil {
+			if len(it.requests) == 0 {
+				it.Close()
+				return false
+			}
+			stream, err := it.client.Read(it.ctx, it.requests[0])
+			if err != nil {
+				it.fail(err)
+				return false
+			}
+			it.requests = it.requests[1:]
+			it.stream = stream
+		}
+		rep, err := it.stream.Recv()
+		if err == io.EOF {
+			it.stream = nil
+			continue
+		}
+		if err != nil {
+			it.fail(err)
+			return false
+		}
+		it.entities = rep.Entities
+	}
+	it.entity = it.entities[0]
+	// do not keep a reference to the entities which were already returned
+	it.entities[0] = nil
+	it.entities = it.entities[1:]
+	return true
+}
+
+// Entity returns the current entity, or nil if Next was not called or
+// returned false.
+func (it *EntityIterator) Entity() *p4_v1.Entity {
+	return it.entity
+}
+
+// Err returns the error which made Next return false, if any. Errors from the
+// RPC are returned unchanged, so that they can be handled as gRPC errors.
+func (it *EntityIterator) Err() error {
+	return it.err
+}
+
+// Close cancels the RPC if it is still in progress. It is safe to call Close
+// several times, and it must be called if the iteration stops before Next
+// returns false.
+func (it *EntityIterator) Close() {
+	if it.closed {
+		return
+	}
+	it.closed = true
+	it.cancel()
+	it.stream = nil
+	it.entities = nil
+	it.entity = nil
+}
+
+func (it *EntityIterator) fail(err error) {
+	it.err = err
+	it.Close()
+}
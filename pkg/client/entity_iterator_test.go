@@ -0,0 +1,102 @@
+package client
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	p4_v1 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+func newCounterEntity(index int64) *p4_v1.Entity {
+	return &p4_v1.Entity{
+		Entity: &p4_v1.Entity_CounterEntry{
+			CounterEntry: &p4_v1.CounterEntry{Index: &p4_v1.Index{Index: index}},
+		},
+	}
+}
+
+// TestEntityIterator ensures that the iterator streams the entities of all the ReadResponses, and
+// that Close cancels the RPC.
+func TestEntityIterator(t *testing.T) {
+	var readCtx context.Context
+	responses := 0
+	recvErr := io.EOF
+	p4RtClient := &fakeP4RuntimeClient{
+		readFn: func(ctx context.Context, in *p4_v1.ReadRequest, opts ...grpc.CallOption) (p4_v1.P4Runtime_ReadClient, error) {
+			readCtx = ctx
+			responses = 0
+			return &fakeP4RuntimeReadClient{
+				recvFn: func() (*p4_v1.ReadResponse, error) {
+					if responses == 3 {
+						return nil, recvErr
+					}
+					responses++
+					return &p4_v1.ReadResponse{Entities: []*p4_v1.Entity{
+						newCounterEntity(int64(2 * responses)),
+						newCounterEntity(int64(2*responses + 1)),
+					}}, nil
+				},
+			}, nil
+		},
+	}
+	fakeClient := newTestClient(p4RtClient, nil)
+
+	t.Run("All", func(t *testing.T) {
+		it := fakeClient.ReadEntityIterator(context.Background(), newCounterEntity(0))
+		defer it.Close()
+		var indexes []int64
+		for it.Next() {
+			indexes = append(indexes, it.Entity().GetCounterEntry().GetIndex().GetIndex())
+		}
+		assert.NoError(t, it.Err())
+		assert.Equal(t, []int64{2, 3, 4, 5, 6, 7}, indexes)
+		assert.Nil(t, it.Entity())
+		assert.Error(t, readCtx.Err(), "RPC context should be cancelled at the end")
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		it := fakeClient.ReadEntityIterator(context.Background(), newCounterEntity(0))
+		require.True(t, it.Next())
+		require.NoError(t, readCtx.Err())
+		it.Close()
+		assert.Error(t, readCtx.Err(), "RPC context should be cancelled by Close")
+		assert.False(t, it.Next())
+		assert.NoError(t, it.Err())
+		assert.Equal(t, 1, responses, "no more responses should be received after Close")
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		recvErr = status.Error(codes.Unavailable, "connection lost")
+		defer func() { recvErr = io.EOF }()
+		it := fakeClient.ReadEntityIterator(context.Background(), newCounterEntity(0))
+		defer it.Close()
+		count := 0
+		for it.Next() {
+			count++
+		}
+		assert.Equal(t, 6, count)
+		assert.Equal(t, codes.Unavailable, status.Code(it.Err()))
+	})
+
+	t.Run("ReadEntityWildcard", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		readEntityCh := make(chan *p4_v1.Entity)
+		errCh := make(chan error)
+		go func() {
+			errCh <- fakeClient.ReadEntityWildcard(ctx, newCounterEntity(0), readEntityCh)
+		}()
+		<-readEntityCh
+		// stop receiving from the channel
+		cancel()
+		assert.Equal(t, context.Canceled, <-errCh)
+		_, ok := <-readEntityCh
+		assert.False(t, ok)
+	})
+}
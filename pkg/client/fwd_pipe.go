@@ -35,6 +35,7 @@ func (c *Client) SetFwdPipeFromBytesWithAction(ctx context.Context, binBytes, p4
 	req := &p4_v1.SetForwardingPipelineConfigRequest{
 		DeviceId:   c.deviceID,
 		ElectionId: &c.electionID,
+		Role:       c.RoleName,
 		Action:     action,
 		Config:     config,
 	}
@@ -63,6 +64,7 @@ func (c *Client) CommitFwdPipe(ctx context.Context) (*p4_v1.SetForwardingPipelin
 	req := &p4_v1.SetForwardingPipelineConfigRequest{
 		DeviceId:   c.deviceID,
 		ElectionId: &c.electionID,
+		Role:       c.RoleName,
 		Action:     p4_v1.SetForwardingPipelineConfigRequest_COMMIT,
 	}
 	return c.SetForwardingPipelineConfig(ctx, req)
@@ -3,15 +3,10 @@ package client
 import (
 	"context"
 	"fmt"
-	"sync"
 
 	p4_v1 "github.com/p4lang/p4runtime/go/p4/v1"
 )
 
-const (
-	meterWildcardReadChSize = 100
-)
-
 func (c *Client) ReadMeterEntry(ctx context.Context, meter string, index int64) (*p4_v1.MeterConfig, error) {
 	meterID := c.meterId(meter)
 	if meterID == invalidID {
@@ -45,33 +40,19 @@ func (c *Client) ReadMeterEntryWildcard(ctx context.Context, meter string) ([]*p
 		MeterId: p4Meter.Preamble.Id,
 	}
 	out := make([]*p4_v1.MeterEntry, 0, p4Meter.Size)
-	readEntityCh := make(chan *p4_v1.Entity, meterWildcardReadChSize)
-	var wg sync.WaitGroup
-	var err error
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		for readEntity := range readEntityCh {
-			readEntry := readEntity.GetMeterEntry()
-			if readEntry != nil {
-				out = append(out, readEntry)
-			} else if err == nil {
-				// only set the error if this is the first error we encounter
-				// do not stop reading from the channel, as doing so would cause
-				// ReadEntityWildcard to block indefinitely
-				err = fmt.Errorf("server returned an entity which is not a meter entry")
-			}
-		}
-	}()
-	if err := c.ReadEntityWildcard(ctx, &p4_v1.Entity{
+	it := c.ReadEntityIterator(ctx, &p4_v1.Entity{
 		Entity: &p4_v1.Entity_MeterEntry{MeterEntry: entry},
-	}, readEntityCh); err != nil {
-		// 原样返回err,以便后续可以以GRPC的错误进行处理
-		// return nil, fmt.Errorf("error when reading meter entries: %v", err)
-		return nil, err
+	})
+	defer it.Close()
+	for it.Next() {
+		readEntry := it.Entity().GetMeterEntry()
+		if readEntry == nil {
+			return nil, fmt.Errorf("server returned an entity which is not a meter entry")
+		}
+		out = append(out, readEntry)
 	}
-	wg.Wait()
-	if err != nil {
+	// 原样返回err,以便后续可以以GRPC的错误进行处理
+	if err := it.Err(); err != nil {
 		return nil, err
 	}
 	return out, nil
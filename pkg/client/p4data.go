@@ -0,0 +1,210 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+
+	p4_config_v1 "github.com/p4lang/p4runtime/go/p4/config/v1"
+	p4_v1 "github.com/p4lang/p4runtime/go/p4/v1"
+
+	"github.com/RainyBow/p4runtime-go-client/pkg/util/conversion"
+)
+
+// DecodeP4Data converts p4_v1.P4Data to a tree of Go values, using typeSpec
+// and the type information in the P4Info to name struct and header members:
+//
+//	bit<W>, int<W>, varbit<W>           => []byte
+//	bool                                => bool
+//	tuple, header stack                 => []interface{}
+//	struct, header                      => map[string]interface{}
+//	header union                        => map[string]interface{} with only the valid header, if any
+//	enum, error                         => string
+//	serializable enum                   => string (member name), or []byte if the value has no name
+//
+// Invalid headers are decoded as a nil map[string]interface{}.
+func (c *Client) DecodeP4Data(data *p4_v1.P4Data, typeSpec *p4_config_v1.P4DataTypeSpec) (interface{}, error) {
+	typeInfo := c.p4Info.GetTypeInfo()
+	switch spec := typeSpec.GetTypeSpec().(type) {
+	case *p4_config_v1.P4DataTypeSpec_Bitstring:
+		switch d := data.GetData().(type) {
+		case *p4_v1.P4Data_Bitstring:
+			return d.Bitstring, nil
+		case *p4_v1.P4Data_Varbit:
+			return d.Varbit.GetBitstring(), nil
+		}
+		return nil, fmt.Errorf("expected a bitstring but got %T", data.GetData())
+	case *p4_config_v1.P4DataTypeSpec_Bool:
+		d, ok := data.GetData().(*p4_v1.P4Data_Bool)
+		if !ok {
+			return nil, fmt.Errorf("expected a bool but got %T", data.GetData())
+		}
+		return d.Bool, nil
+	case *p4_config_v1.P4DataTypeSpec_Tuple:
+		d, ok := data.GetData().(*p4_v1.P4Data_Tuple)
+		if !ok {
+			return nil, fmt.Errorf("expected a tuple but got %T", data.GetData())
+		}
+		members := spec.Tuple.GetMembers()
+		if len(d.Tuple.GetMembers()) != len(members) {
+			return nil, fmt.Errorf("expected %d tuple members but got %d", len(members), len(d.Tuple.GetMembers()))
+		}
+		out := make([]interface{}, 0, len(members))
+		for idx, member := range d.Tuple.GetMembers() {
+			value, err := c.DecodeP4Data(member, members[idx])
+			if err != nil {
+				return nil, fmt.Errorf("tuple member %d: %v", idx, err)
+			}
+			out = append(out, value)
+		}
+		return out, nil
+	case *p4_config_v1.P4DataTypeSpec_Struct:
+		d, ok := data.GetData().(*p4_v1.P4Data_Struct)
+		if !ok {
+			return nil, fmt.Errorf("expected a struct but got %T", data.GetData())
+		}
+		structSpec, ok := typeInfo.GetStructs()[spec.Struct.GetName()]
+		if !ok {
+			return nil, fmt.Errorf("can not find struct %s in p4info", spec.Struct.GetName())
+		}
+		members := structSpec.GetMembers()
+		if len(d.Struct.GetMembers()) != len(members) {
+			return nil, fmt.Errorf("expected %d members for struct %s but got %d", len(members), spec.Struct.GetName(), len(d.Struct.GetMembers()))
+		}
+		out := make(map[string]interface{}, len(members))
+		for idx, member := range d.Struct.GetMembers() {
+			value, err := c.DecodeP4Data(member, members[idx].GetTypeSpec())
+			if err != nil {
+				return nil, fmt.Errorf("struct %s member %s: %v", spec.Struct.GetName(), members[idx].GetName(), err)
+			}
+			out[members[idx].GetName()] = value
+		}
+		return out, nil
+	case *p4_config_v1.P4DataTypeSpec_Header:
+		d, ok := data.GetData().(*p4_v1.P4Data_Header)
+		if !ok {
+			return nil, fmt.Errorf("expected a header but got %T", data.GetData())
+		}
+		return c.decodeP4Header(d.Header, spec.Header.GetName())
+	case *p4_config_v1.P4DataTypeSpec_HeaderUnion:
+		d, ok := data.GetData().(*p4_v1.P4Data_HeaderUnion)
+		if !ok {
+			return nil, fmt.Errorf("expected a header union but got %T", data.GetData())
+		}
+		return c.decodeP4HeaderUnion(d.HeaderUnion, spec.HeaderUnion.GetName())
+	case *p4_config_v1.P4DataTypeSpec_HeaderStack:
+		d, ok := data.GetData().(*p4_v1.P4Data_HeaderStack)
+		if !ok {
+			return nil, fmt.Errorf("expected a header stack but got %T", data.GetData())
+		}
+		out := make([]interface{}, 0, len(d.HeaderStack.GetEntries()))
+		for idx, entry := range d.HeaderStack.GetEntries() {
+			value, err := c.decodeP4Header(entry, spec.HeaderStack.GetHeader().GetName())
+			if err != nil {
+				return nil, fmt.Errorf("header stack entry %d: %v", idx, err)
+			}
+			out = append(out, value)
+		}
+		return out, nil
+	case *p4_config_v1.P4DataTypeSpec_HeaderUnionStack:
+		d, ok := data.GetData().(*p4_v1.P4Data_HeaderUnionStack)
+		if !ok {
+			return nil, fmt.Errorf("expected a header union stack but got %T", data.GetData())
+		}
+		out := make([]interface{}, 0, len(d.HeaderUnionStack.GetEntries()))
+		for idx, entry := range d.HeaderUnionStack.GetEntries() {
+			value, err := c.decodeP4HeaderUnion(entry, spec.HeaderUnionStack.GetHeaderUnion().GetName())
+			if err != nil {
+				return nil, fmt.Errorf("header union stack entry %d: %v", idx, err)
+			}
+			out = append(out, value)
+		}
+		return out, nil
+	case *p4_config_v1.P4DataTypeSpec_Enum:
+		d, ok := data.GetData().(*p4_v1.P4Data_Enum)
+		if !ok {
+			return nil, fmt.Errorf("expected an enum but got %T", data.GetData())
+		}
+		return d.Enum, nil
+	case *p4_config_v1.P4DataTypeSpec_Error:
+		d, ok := data.GetData().(*p4_v1.P4Data_Error)
+		if !ok {
+			return nil, fmt.Errorf("expected an error but got %T", data.GetData())
+		}
+		return d.Error, nil
+	case *p4_config_v1.P4DataTypeSpec_SerializableEnum:
+		var value []byte
+		switch d := data.GetData().(type) {
+		case *p4_v1.P4Data_EnumValue:
+			value = d.EnumValue
+		case *p4_v1.P4Data_Bitstring:
+			value = d.Bitstring
+		default:
+			return nil, fmt.Errorf("expected a serializable enum but got %T", data.GetData())
+		}
+		enumSpec := typeInfo.GetSerializableEnums()[spec.SerializableEnum.GetName()]
+		for _, member := range enumSpec.GetMembers() {
+			if bytes.Equal(conversion.ToCanonicalBytestring(member.GetValue()), conversion.ToCanonicalBytestring(value)) {
+				return member.GetName(), nil
+			}
+		}
+		return value, nil
+	case *p4_config_v1.P4DataTypeSpec_NewType:
+		newTypeSpec, ok := typeInfo.GetNewTypes()[spec.NewType.GetName()]
+		if !ok {
+			return nil, fmt.Errorf("can not find type %s in p4info", spec.NewType.GetName())
+		}
+		if originalType := newTypeSpec.GetOriginalType(); originalType != nil {
+			return c.DecodeP4Data(data, originalType)
+		}
+		// translated types are sent as bitstrings
+		d, ok := data.GetData().(*p4_v1.P4Data_Bitstring)
+		if !ok {
+			return nil, fmt.Errorf("expected a bitstring for type %s but got %T", spec.NewType.GetName(), data.GetData())
+		}
+		return d.Bitstring, nil
+	default:
+		return nil, fmt.Errorf("unsupported type spec %T", typeSpec.GetTypeSpec())
+	}
+}
+
+func (c *Client) decodeP4Header(header *p4_v1.P4Header, name string) (map[string]interface{}, error) {
+	headerSpec, ok := c.p4Info.GetTypeInfo().GetHeaders()[name]
+	if !ok {
+		return nil, fmt.Errorf("can not find header %s in p4info", name)
+	}
+	if !header.GetIsValid() {
+		return nil, nil
+	}
+	members := headerSpec.GetMembers()
+	if len(header.GetBitstrings()) != len(members) {
+		return nil, fmt.Errorf("expected %d fields for header %s but got %d", len(members), name, len(header.GetBitstrings()))
+	}
+	out := make(map[string]interface{}, len(members))
+	for idx, bitstring := range header.GetBitstrings() {
+		out[members[idx].GetName()] = bitstring
+	}
+	return out, nil
+}
+
+func (c *Client) decodeP4HeaderUnion(headerUnion *p4_v1.P4HeaderUnion, name string) (map[string]interface{}, error) {
+	unionSpec, ok := c.p4Info.GetTypeInfo().GetHeaderUnions()[name]
+	if !ok {
+		return nil, fmt.Errorf("can not find header union %s in p4info", name)
+	}
+	out := make(map[string]interface{}, 1)
+	if headerUnion.GetValidHeaderName() == "" {
+		return out, nil
+	}
+	for _, member := range unionSpec.GetMembers() {
+		if member.GetName() != headerUnion.GetValidHeaderName() {
+			continue
+		}
+		header, err := c.decodeP4Header(headerUnion.GetValidHeader(), member.GetHeader().GetName())
+		if err != nil {
+			return nil, fmt.Errorf("header union %s member %s: %v", name, member.GetName(), err)
+		}
+		out[member.GetName()] = header
+		return out, nil
+	}
+	return nil, fmt.Errorf("can not find member %s of header union %s in p4info", headerUnion.GetValidHeaderName(), name)
+}
@@ -130,16 +130,36 @@ func (c *Client) actionProfileId(name string) uint32 {
 	return invalidID
 }
 
-func (c *Client) digestId(name string) uint32 {
+func (c *Client) findDigest(name string) *p4_config_v1.Digest {
 	if c.p4Info == nil {
-		return invalidID
+		return nil
 	}
 	for _, digest := range c.p4Info.Digests {
 		if digest.Preamble.Name == name {
-			return digest.Preamble.Id
+			return digest
 		}
 	}
-	return invalidID
+	return nil
+}
+
+func (c *Client) findDigestById(id uint32) *p4_config_v1.Digest {
+	if c.p4Info == nil {
+		return nil
+	}
+	for _, digest := range c.p4Info.Digests {
+		if digest.Preamble.Id == id {
+			return digest
+		}
+	}
+	return nil
+}
+
+func (c *Client) digestId(name string) uint32 {
+	digest := c.findDigest(name)
+	if digest == nil {
+		return invalidID
+	}
+	return digest.Preamble.Id
 }
 
 func (c *Client) findCounter(name string) *p4_config_v1.Counter {
@@ -181,3 +201,63 @@ func (c *Client) meterId(name string) uint32 {
 	}
 	return meter.Preamble.Id
 }
+
+// findDirectCounterInTable returns the direct counter of table, if any.
+func (c *Client) findDirectCounterInTable(table *p4_config_v1.Table) *p4_config_v1.DirectCounter {
+	if c.p4Info == nil {
+		return nil
+	}
+	for _, id := range table.DirectResourceIds {
+		for _, counter := range c.p4Info.DirectCounters {
+			if counter.Preamble.Id == id {
+				return counter
+			}
+		}
+	}
+	return nil
+}
+
+// findDirectMeterInTable returns the direct meter of table, if any.
+func (c *Client) findDirectMeterInTable(table *p4_config_v1.Table) *p4_config_v1.DirectMeter {
+	if c.p4Info == nil {
+		return nil
+	}
+	for _, id := range table.DirectResourceIds {
+		for _, meter := range c.p4Info.DirectMeters {
+			if meter.Preamble.Id == id {
+				return meter
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Client) findControllerPacketMetadata(name string) *p4_config_v1.ControllerPacketMetadata {
+	if c.p4Info == nil {
+		return nil
+	}
+	for _, pktMd := range c.p4Info.ControllerPacketMetadata {
+		if pktMd.Preamble.Name == name {
+			return pktMd
+		}
+	}
+	return nil
+}
+
+func (c *Client) packetMetadataId(pktMd *p4_config_v1.ControllerPacketMetadata, name string) uint32 {
+	for _, md := range pktMd.Metadata {
+		if md.Name == name {
+			return md.Id
+		}
+	}
+	return invalidID
+}
+
+func (c *Client) packetMetadataName(pktMd *p4_config_v1.ControllerPacketMetadata, id uint32) string {
+	for _, md := range pktMd.Metadata {
+		if md.Id == id {
+			return md.Name
+		}
+	}
+	return unknownName
+}
@@ -0,0 +1,106 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	p4_v1 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+const (
+	packetOutMetadataName = "packet_out"
+	packetInMetadataName  = "packet_in"
+)
+
+// bitwidthOf returns the minimum number of bits required to represent the
+// binary string v.
+func bitwidthOf(v []byte) int {
+	for i, b := range v {
+		if b == 0 {
+			continue
+		}
+		n := 0
+		for ; b != 0; b >>= 1 {
+			n++
+		}
+		return (len(v)-i-1)*8 + n
+	}
+	return 0
+}
+
+// NewPacketOut builds a PacketOut message. metadata is indexed by the names
+// of the "packet_out" ControllerPacketMetadata fields in the P4Info, and each
+// value must fit in the bitwidth of the corresponding field.
+func (c *Client) NewPacketOut(payload []byte, metadata map[string][]byte) (*p4_v1.PacketOut, error) {
+	pktMd := c.findControllerPacketMetadata(packetOutMetadataName)
+	if pktMd == nil {
+		return nil, fmt.Errorf("no '%s' controller packet metadata in p4info", packetOutMetadataName)
+	}
+	for name := range metadata {
+		if c.packetMetadataId(pktMd, name) == invalidID {
+			return nil, fmt.Errorf("unknown packet_out metadata name:%s", name)
+		}
+	}
+	packet := &p4_v1.PacketOut{
+		Payload: payload,
+	}
+	for _, md := range pktMd.Metadata {
+		value, ok := metadata[md.Name]
+		if !ok {
+			continue
+		}
+		if bitwidthOf(value) > int(md.Bitwidth) {
+			return nil, fmt.Errorf("value for packet_out metadata %s does not fit in %d bits", md.Name, md.Bitwidth)
+		}
+		packet.Metadata = append(packet.Metadata, &p4_v1.PacketMetadata{
+			MetadataId: md.Id,
+			Value:      ToCanonicalIf(value, c.CanonicalBytestrings),
+		})
+	}
+	return packet, nil
+}
+
+// SendPacketOut sends a PacketOut on the StreamChannel, and waits until it has
+// been sent or ctx is done. Errors sent back by the server in a StreamError
+// are not reported, see SendPacketOutAsync.
+func (c *Client) SendPacketOut(ctx context.Context, payload []byte, metadata map[string][]byte) error {
+	packet, err := c.NewPacketOut(payload, metadata)
+	if err != nil {
+		return err
+	}
+	m := &p4_v1.StreamMessageRequest{
+		Update: &p4_v1.StreamMessageRequest_Packet{Packet: packet},
+	}
+	result, err := c.SendStreamMessage(ctx, m)
+	if err != nil {
+		return err
+	}
+	return result.Wait(ctx)
+}
+
+// PacketIn is a decoded p4_v1.PacketIn, with metadata indexed by the names of
+// the "packet_in" ControllerPacketMetadata fields in the P4Info.
+type PacketIn struct {
+	Payload  []byte            `json:"payload"`
+	Metadata map[string][]byte `json:"metadata"`
+}
+
+// DecodePacketIn converts p4_v1.PacketIn to PacketIn
+func (c *Client) DecodePacketIn(packet *p4_v1.PacketIn) (*PacketIn, error) {
+	pktMd := c.findControllerPacketMetadata(packetInMetadataName)
+	if pktMd == nil {
+		return nil, fmt.Errorf("no '%s' controller packet metadata in p4info", packetInMetadataName)
+	}
+	packetIn := &PacketIn{
+		Payload:  packet.Payload,
+		Metadata: make(map[string][]byte, len(packet.Metadata)),
+	}
+	for _, md := range packet.Metadata {
+		name := c.packetMetadataName(pktMd, md.MetadataId)
+		if name == unknownName {
+			return nil, fmt.Errorf("can not find packet_in metadata(id=%d) in p4info", md.MetadataId)
+		}
+		packetIn.Metadata[name] = md.Value
+	}
+	return packetIn, nil
+}
@@ -0,0 +1,116 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	p4_config_v1 "github.com/p4lang/p4runtime/go/p4/config/v1"
+	p4_v1 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+func newPacketMetadataP4Info() *p4_config_v1.P4Info {
+	return &p4_config_v1.P4Info{
+		ControllerPacketMetadata: []*p4_config_v1.ControllerPacketMetadata{
+			{
+				Preamble: &p4_config_v1.Preamble{Name: "packet_out", Id: 100},
+				Metadata: []*p4_config_v1.ControllerPacketMetadata_Metadata{
+					{Id: 1, Name: "egress_port", Bitwidth: 9},
+					{Id: 2, Name: "mcast_grp", Bitwidth: 16},
+				},
+			},
+			{
+				Preamble: &p4_config_v1.Preamble{Name: "packet_in", Id: 101},
+				Metadata: []*p4_config_v1.ControllerPacketMetadata_Metadata{
+					{Id: 1, Name: "ingress_port", Bitwidth: 9},
+				},
+			},
+		},
+	}
+}
+
+func TestSendPacketOut(t *testing.T) {
+	fakeClient := newTestClient(&fakeP4RuntimeClient{}, newPacketMetadataP4Info())
+	payload := []byte{'\xab', '\xcd'}
+	var sendErr error
+	stream := &fakeP4RuntimeStreamChannelClient{
+		sendFn: func(*p4_v1.StreamMessageRequest) error {
+			return sendErr
+		},
+	}
+	sendPacketOut := func() error {
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- fakeClient.SendPacketOut(context.Background(), payload, map[string][]byte{
+				"egress_port": {'\x00', '\x01', '\x01'},
+				"mcast_grp":   {'\x00', '\x00'},
+			})
+		}()
+		req := <-fakeClient.streamSendCh
+		select {
+		case <-errCh:
+			require.FailNow(t, "SendPacketOut should wait for the PacketOut to be sent")
+		default:
+		}
+		fakeClient.sendStreamRequest(stream, req)
+		return <-errCh
+	}
+
+	require.NoError(t, sendPacketOut())
+	sendErr = errors.New("stream is broken")
+	assert.Equal(t, &StreamChannelError{Op: "send", Err: sendErr}, sendPacketOut())
+
+	// Run is not running
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, context.Canceled, fakeClient.SendPacketOut(ctx, payload, nil))
+}
+
+func TestNewPacketOut(t *testing.T) {
+	fakeClient := newTestClient(&fakeP4RuntimeClient{}, newPacketMetadataP4Info())
+	payload := []byte{'\xab', '\xcd'}
+
+	packet, err := fakeClient.NewPacketOut(payload, map[string][]byte{
+		"egress_port": {'\x00', '\x01', '\x01'},
+		"mcast_grp":   {'\x00', '\x00'},
+	})
+	require.NoError(t, err)
+	require.NotNil(t, packet)
+	assert.Equal(t, payload, packet.Payload)
+	assert.Equal(t, []*p4_v1.PacketMetadata{
+		{MetadataId: 1, Value: []byte{'\x01', '\x01'}},
+		{MetadataId: 2, Value: []byte{'\x00'}},
+	}, packet.Metadata)
+}
+
+func TestNewPacketOut_BadMetadata(t *testing.T) {
+	fakeClient := newTestClient(&fakeP4RuntimeClient{}, newPacketMetadataP4Info())
+
+	_, err := fakeClient.NewPacketOut(nil, map[string][]byte{"egress_port": {'\x02', '\x00'}})
+	assert.EqualError(t, err, "value for packet_out metadata egress_port does not fit in 9 bits")
+
+	_, err = fakeClient.NewPacketOut(nil, map[string][]byte{"ingress_port": {'\x01'}})
+	assert.EqualError(t, err, "unknown packet_out metadata name:ingress_port")
+}
+
+func TestDecodePacketIn(t *testing.T) {
+	fakeClient := newTestClient(&fakeP4RuntimeClient{}, newPacketMetadataP4Info())
+	payload := []byte{'\xab', '\xcd'}
+
+	packetIn, err := fakeClient.DecodePacketIn(&p4_v1.PacketIn{
+		Payload:  payload,
+		Metadata: []*p4_v1.PacketMetadata{{MetadataId: 1, Value: []byte{'\x01'}}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, payload, packetIn.Payload)
+	assert.Equal(t, map[string][]byte{"ingress_port": {'\x01'}}, packetIn.Metadata)
+
+	_, err = fakeClient.DecodePacketIn(&p4_v1.PacketIn{
+		Payload:  payload,
+		Metadata: []*p4_v1.PacketMetadata{{MetadataId: 2, Value: []byte{'\x01'}}},
+	})
+	assert.EqualError(t, err, "can not find packet_in metadata(id=2) in p4info")
+}
@@ -3,15 +3,10 @@ package client
 import (
 	"context"
 	"fmt"
-	"sync"
 
 	p4_v1 "github.com/p4lang/p4runtime/go/p4/v1"
 )
 
-var (
-	multicastGroupWildcardReadChSize = 100
-)
-
 func (c *Client) InsertMulticastGroup(ctx context.Context, mgid uint32, ports []uint32) error {
 	entry := &p4_v1.MulticastGroupEntry{
 		MulticastGroupId: mgid,
@@ -99,6 +94,25 @@ func (c *Client) DeleteMulticastGroup(ctx context.Context, mgid uint32) error {
 	return c.WriteUpdate(ctx, update)
 }
 
+// UpsertMulticastGroup inserts the multicast group, or modifies it if it
+// already exists.
+func (c *Client) UpsertMulticastGroup(ctx context.Context, mgid uint32, ports []uint32) error {
+	err := c.InsertMulticastGroup(ctx, mgid, ports)
+	if IsAlreadyExists(err) {
+		return c.ModifyMulticastGroup(ctx, mgid, ports)
+	}
+	return err
+}
+
+// DeleteMulticastGroupIfExists deletes the multicast group, and succeeds if it
+// does not exist.
+func (c *Client) DeleteMulticastGroupIfExists(ctx context.Context, mgid uint32) error {
+	if err := c.DeleteMulticastGroup(ctx, mgid); err != nil && !IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
 // read all mutlicast group entry
 func (c *Client) ReadMulticastGroupWildcard(ctx context.Context) ([]*p4_v1.MulticastGroupEntry, error) {
 	entry := &p4_v1.PacketReplicationEngineEntry{
@@ -106,39 +120,21 @@ func (c *Client) ReadMulticastGroupWildcard(ctx context.Context) ([]*p4_v1.Multi
 	}
 
 	out := make([]*p4_v1.MulticastGroupEntry, 0)
-	readEntityCh := make(chan *p4_v1.Entity, multicastGroupWildcardReadChSize)
-
-	var wg sync.WaitGroup
-	var err error
-	wg.Add(1)
-
-	go func() {
-		defer wg.Done()
-		for readEntity := range readEntityCh {
-			readEntry := readEntity.GetPacketReplicationEngineEntry()
-			if readEntry != nil {
-				out = append(out, readEntry.GetMulticastGroupEntry())
-			} else if err == nil {
-				// only set the error if this is the first error we encounter
-				// dp not stop reading from the channel, as doing so would cause
-				// ReadEntityWildcard to block indefinitely
-				err = fmt.Errorf("server returned an entity which is not a mutlicastgroup entry!")
-			}
-		}
-	}()
-
-	if err := c.ReadEntityWildcard(ctx, &p4_v1.Entity{
+	it := c.ReadEntityIterator(ctx, &p4_v1.Entity{
 		Entity: &p4_v1.Entity_PacketReplicationEngineEntry{
 			PacketReplicationEngineEntry: entry,
 		},
-	}, readEntityCh); err != nil {
-		// 原样返回err,以便后续可以以GRPC的错误进行处理
-		// return nil, fmt.Errorf("error when reading table entries: %v", err)
-		return nil, err
+	})
+	defer it.Close()
+	for it.Next() {
+		readEntry := it.Entity().GetPacketReplicationEngineEntry()
+		if readEntry == nil {
+			return nil, fmt.Errorf("server returned an entity which is not a mutlicastgroup entry!")
+		}
+		out = append(out, readEntry.GetMulticastGroupEntry())
 	}
-
-	wg.Wait()
-	if err != nil {
+	// 原样返回err,以便后续可以以GRPC的错误进行处理
+	if err := it.Err(); err != nil {
 		return nil, err
 	}
 	return out, nil
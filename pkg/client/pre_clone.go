@@ -3,15 +3,10 @@ package client
 import (
 	"context"
 	"fmt"
-	"sync"
 
 	p4_v1 "github.com/p4lang/p4runtime/go/p4/v1"
 )
 
-var (
-	cloneSessionWildcardReadChSize = 100
-)
-
 func (c *Client) InsertCloneSession(ctx context.Context, session_id uint32, packet_length int32, ports []uint32) error {
 	entry := &p4_v1.CloneSessionEntry{
 		SessionId:         session_id,
@@ -101,6 +96,25 @@ func (c *Client) DeleteCloneSession(ctx context.Context, session_id uint32) erro
 	return c.WriteUpdate(ctx, update)
 }
 
+// UpsertCloneSession inserts the clone session, or modifies it if it already
+// exists.
+func (c *Client) UpsertCloneSession(ctx context.Context, session_id uint32, packet_length int32, ports []uint32) error {
+	err := c.InsertCloneSession(ctx, session_id, packet_length, ports)
+	if IsAlreadyExists(err) {
+		return c.ModifyCloneSession(ctx, session_id, packet_length, ports)
+	}
+	return err
+}
+
+// DeleteCloneSessionIfExists deletes the clone session, and succeeds if it
+// does not exist.
+func (c *Client) DeleteCloneSessionIfExists(ctx context.Context, session_id uint32) error {
+	if err := c.DeleteCloneSession(ctx, session_id); err != nil && !IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
 // read all clone session entry
 func (c *Client) ReadCloneSessionWildcard(ctx context.Context) ([]*p4_v1.CloneSessionEntry, error) {
 	entry := &p4_v1.PacketReplicationEngineEntry{
@@ -108,39 +122,21 @@ func (c *Client) ReadCloneSessionWildcard(ctx context.Context) ([]*p4_v1.CloneSe
 	}
 
 	out := make([]*p4_v1.CloneSessionEntry, 0)
-	readEntityCh := make(chan *p4_v1.Entity, cloneSessionWildcardReadChSize)
-
-	var wg sync.WaitGroup
-	var err error
-	wg.Add(1)
-
-	go func() {
-		defer wg.Done()
-		for readEntity := range readEntityCh {
-			readEntry := readEntity.GetPacketReplicationEngineEntry()
-			if readEntry != nil {
-				out = append(out, readEntry.GetCloneSessionEntry())
-			} else if err == nil {
-				// only set the error if this is the first error we encounter
-				// dp not stop reading from the channel, as doing so would cause
-				// ReadEntityWildcard to block indefinitely
-				err = fmt.Errorf("server returned an entity which is not a mutlicastgroup entry!")
-			}
-		}
-	}()
-
-	if err := c.ReadEntityWildcard(ctx, &p4_v1.Entity{
+	it := c.ReadEntityIterator(ctx, &p4_v1.Entity{
 		Entity: &p4_v1.Entity_PacketReplicationEngineEntry{
 			PacketReplicationEngineEntry: entry,
 		},
-	}, readEntityCh); err != nil {
-		// 原样返回err,以便后续可以以GRPC的错误进行处理
-		// return nil, fmt.Errorf("error when reading table entries: %v", err)
-		return nil, err
+	})
+	defer it.Close()
+	for it.Next() {
+		readEntry := it.Entity().GetPacketReplicationEngineEntry()
+		if readEntry == nil {
+			return nil, fmt.Errorf("server returned an entity which is not a clone session entry!")
+		}
+		out = append(out, readEntry.GetCloneSessionEntry())
 	}
-
-	wg.Wait()
-	if err != nil {
+	// 原样返回err,以便后续可以以GRPC的错误进行处理
+	if err := it.Err(); err != nil {
 		return nil, err
 	}
 	return out, nil
@@ -0,0 +1,221 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	p4_v1 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+// ReadQuery is one of the entities read by ReadMany. Like in a ReadRequest,
+// the fields which are not set are wildcards.
+type ReadQuery struct {
+	Entity *p4_v1.Entity
+	// error when building the query, e.g. unknown P4Info name
+	err error
+}
+
+// EntityQuery returns a query for entity.
+func EntityQuery(entity *p4_v1.Entity) *ReadQuery {
+	return &ReadQuery{Entity: entity}
+}
+
+// TableEntryQuery returns a query for all the entries of table.
+func (c *Client) TableEntryQuery(table string) *ReadQuery {
+	tableID := c.tableId(table)
+	if tableID == invalidID {
+		return &ReadQuery{err: fmt.Errorf("table %s not found", table)}
+	}
+	return EntityQuery(&p4_v1.Entity{
+		Entity: &p4_v1.Entity_TableEntry{TableEntry: &p4_v1.TableEntry{TableId: tableID}},
+	})
+}
+
+// CounterEntryQuery returns a query for all the entries of counter.
+func (c *Client) CounterEntryQuery(counter string) *ReadQuery {
+	counterID := c.counterId(counter)
+	if counterID == invalidID {
+		return &ReadQuery{err: fmt.Errorf("counter %s not found", counter)}
+	}
+	return EntityQuery(&p4_v1.Entity{
+		Entity: &p4_v1.Entity_CounterEntry{CounterEntry: &p4_v1.CounterEntry{CounterId: counterID}},
+	})
+}
+
+// MeterEntryQuery returns a query for all the entries of meter.
+func (c *Client) MeterEntryQuery(meter string) *ReadQuery {
+	meterID := c.meterId(meter)
+	if meterID == invalidID {
+		return &ReadQuery{err: fmt.Errorf("meter %s not found", meter)}
+	}
+	return EntityQuery(&p4_v1.Entity{
+		Entity: &p4_v1.Entity_MeterEntry{MeterEntry: &p4_v1.MeterEntry{MeterId: meterID}},
+	})
+}
+
+// MulticastGroupQuery returns a query for all the multicast groups.
+func MulticastGroupQuery() *ReadQuery {
+	return EntityQuery(&p4_v1.Entity{
+		Entity: &p4_v1.Entity_PacketReplicationEngineEntry{
+			PacketReplicationEngineEntry: &p4_v1.PacketReplicationEngineEntry{
+				Type: &p4_v1.PacketReplicationEngineEntry_MulticastGroupEntry{
+					MulticastGroupEntry: &p4_v1.MulticastGroupEntry{},
+				},
+			},
+		},
+	})
+}
+
+// CloneSessionQuery returns a query for all the clone sessions.
+func CloneSessionQuery() *ReadQuery {
+	return EntityQuery(&p4_v1.Entity{
+		Entity: &p4_v1.Entity_PacketReplicationEngineEntry{
+			PacketReplicationEngineEntry: &p4_v1.PacketReplicationEngineEntry{
+				Type: &p4_v1.PacketReplicationEngineEntry_CloneSessionEntry{
+					CloneSessionEntry: &p4_v1.CloneSessionEntry{},
+				},
+			},
+		},
+	})
+}
+
+// ReadResult holds the entities read for a ReadQuery.
+type ReadResult struct {
+	Query    *ReadQuery
+	Entities []*p4_v1.Entity
+}
+
+func (r *ReadResult) TableEntries() []*p4_v1.TableEntry {
+	out := make([]*p4_v1.TableEntry, 0, len(r.Entities))
+	for _, entity := range r.Entities {
+		out = append(out, entity.GetTableEntry())
+	}
+	return out
+}
+
+func (r *ReadResult) CounterEntries() []*p4_v1.CounterEntry {
+	out := make([]*p4_v1.CounterEntry, 0, len(r.Entities))
+	for _, entity := range r.Entities {
+		out = append(out, entity.GetCounterEntry())
+	}
+	return out
+}
+
+func (r *ReadResult) MeterEntries() []*p4_v1.MeterEntry {
+	out := make([]*p4_v1.MeterEntry, 0, len(r.Entities))
+	for _, entity := range r.Entities {
+		out = append(out, entity.GetMeterEntry())
+	}
+	return out
+}
+
+func (r *ReadResult) MulticastGroupEntries() []*p4_v1.MulticastGroupEntry {
+	out := make([]*p4_v1.MulticastGroupEntry, 0, len(r.Entities))
+	for _, entity := range r.Entities {
+		out = append(out, entity.GetPacketReplicationEngineEntry().GetMulticastGroupEntry())
+	}
+	return out
+}
+
+func (r *ReadResult) CloneSessionEntries() []*p4_v1.CloneSessionEntry {
+	out := make([]*p4_v1.CloneSessionEntry, 0, len(r.Entities))
+	for _, entity := range r.Entities {
+		out = append(out, entity.GetPacketReplicationEngineEntry().GetCloneSessionEntry())
+	}
+	return out
+}
+
+// ReadMany reads the entities of all the queries with a single ReadRequest,
+// unless it exceeds MaxRequestBytes. It returns one result for each query, in
+// the same order. Each entity returned by the server goes to the first query
+// which it matches, so overlapping queries should be avoided.
+func (c *Client) ReadMany(ctx context.Context, queries ...*ReadQuery) ([]*ReadResult, error) {
+	results := make([]*ReadResult, 0, len(queries))
+	entities := make([]*p4_v1.Entity, 0, len(queries))
+	keys := make([]protoreflect.Message, 0, len(queries))
+	for _, query := range queries {
+		if query.err != nil {
+			return nil, query.err
+		}
+		results = append(results, &ReadResult{Query: query})
+		entities = append(entities, query.Entity)
+		keys = append(keys, entityKey(query.Entity).ProtoReflect())
+	}
+	if len(queries) == 0 {
+		return results, nil
+	}
+
+	it := c.newEntityIterator(ctx, entities)
+	defer it.Close()
+	for it.Next() {
+		entity := it.Entity()
+		found := false
+		for idx, key := range keys {
+			if wildcardMatch(key, entity.ProtoReflect()) {
+				results[idx].Entities = append(results[idx].Entities, entity)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("server returned an entity which does not match any query: %v", entity)
+		}
+	}
+	// 原样返回err,以便后续可以以GRPC的错误进行处理
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// wildcardMatch returns true if all the fields set in query are equal in m,
+// recursively. Unset fields are wildcards, as in a ReadRequest.
+func wildcardMatch(query, m protoreflect.Message) bool {
+	match := true
+	query.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if !m.Has(fd) {
+			match = false
+		} else if fd.Kind() == protoreflect.MessageKind && fd.Cardinality() != protoreflect.Repeated {
+			match = wildcardMatch(v.Message(), m.Get(fd).Message())
+		} else {
+			match = valueEqual(fd, v, m.Get(fd))
+		}
+		return match
+	})
+	return match
+}
+
+func valueEqual(fd protoreflect.FieldDescriptor, a, b protoreflect.Value) bool {
+	if fd.IsList() {
+		la, lb := a.List(), b.List()
+		if la.Len() != lb.Len() {
+			return false
+		}
+		for idx := 0; idx < la.Len(); idx++ {
+			if fd.Kind() == protoreflect.MessageKind {
+				if !wildcardMatch(la.Get(idx).Message(), lb.Get(idx).Message()) {
+					return false
+				}
+			} else if !scalarEqual(la.Get(idx), lb.Get(idx)) {
+				return false
+			}
+		}
+		return true
+	}
+	if fd.IsMap() {
+		// there are no map fields in the keys of the P4Runtime entities
+		return false
+	}
+	return scalarEqual(a, b)
+}
+
+func scalarEqual(a, b protoreflect.Value) bool {
+	if ab, ok := a.Interface().([]byte); ok {
+		bb, ok := b.Interface().([]byte)
+		return ok && bytes.Equal(ab, bb)
+	}
+	return a.Interface() == b.Interface()
+}
@@ -0,0 +1,97 @@
+package client
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+
+	p4_config_v1 "github.com/p4lang/p4runtime/go/p4/config/v1"
+	p4_v1 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+// TestReadMany ensures that all the queries are sent in a single ReadRequest, and that the
+// entities are grouped by query.
+func TestReadMany(t *testing.T) {
+	newCounterEntry := func(counterID uint32, index int64) *p4_v1.Entity {
+		return &p4_v1.Entity{
+			Entity: &p4_v1.Entity_CounterEntry{
+				CounterEntry: &p4_v1.CounterEntry{
+					CounterId: counterID,
+					Index:     &p4_v1.Index{Index: index},
+					Data:      &p4_v1.CounterData{PacketCount: index},
+				},
+			},
+		}
+	}
+	var requests []*p4_v1.ReadRequest
+	p4RtClient := &fakeP4RuntimeClient{
+		readFn: func(ctx context.Context, in *p4_v1.ReadRequest, opts ...grpc.CallOption) (p4_v1.P4Runtime_ReadClient, error) {
+			requests = append(requests, in)
+			done := false
+			return &fakeP4RuntimeReadClient{
+				recvFn: func() (*p4_v1.ReadResponse, error) {
+					if done {
+						return nil, io.EOF
+					}
+					done = true
+					return &p4_v1.ReadResponse{Entities: []*p4_v1.Entity{
+						newCounterEntry(1, 0),
+						newCounterEntry(2, 0),
+						newCounterEntry(1, 1),
+						{Entity: &p4_v1.Entity_PacketReplicationEngineEntry{
+							PacketReplicationEngineEntry: &p4_v1.PacketReplicationEngineEntry{
+								Type: &p4_v1.PacketReplicationEngineEntry_MulticastGroupEntry{
+									MulticastGroupEntry: &p4_v1.MulticastGroupEntry{MulticastGroupId: 10},
+								},
+							},
+						}},
+						newCounterEntry(2, 1),
+					}}, nil
+				},
+			}, nil
+		},
+	}
+	p4Info := &p4_config_v1.P4Info{
+		Counters: []*p4_config_v1.Counter{
+			{Preamble: &p4_config_v1.Preamble{Name: "counter1", Id: 1}},
+			{Preamble: &p4_config_v1.Preamble{Name: "counter2", Id: 2}},
+		},
+	}
+	fakeClient := newTestClient(p4RtClient, p4Info)
+
+	results, err := fakeClient.ReadMany(
+		context.Background(),
+		fakeClient.CounterEntryQuery("counter1"),
+		MulticastGroupQuery(),
+		fakeClient.CounterEntryQuery("counter2"),
+	)
+	require.NoError(t, err)
+	require.Len(t, requests, 1)
+	assert.Len(t, requests[0].Entities, 3)
+	require.Len(t, results, 3)
+
+	getIndexes := func(entries []*p4_v1.CounterEntry, counterID uint32) []int64 {
+		var indexes []int64
+		for _, entry := range entries {
+			assert.Equal(t, counterID, entry.CounterId)
+			indexes = append(indexes, entry.Index.Index)
+		}
+		return indexes
+	}
+	assert.Equal(t, []int64{0, 1}, getIndexes(results[0].CounterEntries(), 1))
+	assert.Equal(t, []int64{0, 1}, getIndexes(results[2].CounterEntries(), 2))
+	groups := results[1].MulticastGroupEntries()
+	require.Len(t, groups, 1)
+	assert.Equal(t, uint32(10), groups[0].MulticastGroupId)
+
+	// the server returns an entity which does not match any query
+	_, err = fakeClient.ReadMany(context.Background(), fakeClient.CounterEntryQuery("counter1"))
+	assert.Error(t, err)
+
+	_, err = fakeClient.ReadMany(context.Background(), fakeClient.CounterEntryQuery("foo"))
+	assert.EqualError(t, err, "counter foo not found")
+}
@@ -0,0 +1,48 @@
+package client
+
+import (
+	"context"
+	"sync"
+)
+
+// Result is the outcome of an operation which completes asynchronously.
+type Result struct {
+	once sync.Once
+	done chan struct{}
+	err  error
+}
+
+func newResult() *Result {
+	return &Result{
+		done: make(chan struct{}),
+	}
+}
+
+// resolve completes the operation; only the first call has an effect.
+func (r *Result) resolve(err error) {
+	r.once.Do(func() {
+		r.err = err
+		close(r.done)
+	})
+}
+
+// Done returns a channel which is closed when the operation completes.
+func (r *Result) Done() <-chan struct{} {
+	return r.done
+}
+
+// Err blocks until the operation completes and returns its error.
+func (r *Result) Err() error {
+	<-r.done
+	return r.err
+}
+
+// Wait blocks until the operation completes or ctx is done.
+func (r *Result) Wait(ctx context.Context) error {
+	select {
+	case <-r.done:
+		return r.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,207 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	//nolint:staticcheck // SA1019 To be resolved later
+	//lint:ignore SA1019 This line added for support golint version of VSC
+	"github.com/golang/protobuf/proto"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	p4_v1 "github.com/p4lang/p4runtime/go/p4/v1"
+
+	"github.com/RainyBow/p4runtime-go-client/pkg/util/conversion"
+)
+
+// RetryPolicy controls how writes are retried when they fail with a
+// transient error. Retries are idempotency-aware: when retrying, an INSERT
+// which fails with ALREADY_EXISTS while the existing entity is identical to
+// the inserted one, and a DELETE which fails with NOT_FOUND, are considered
+// successful, as they were most likely applied by a previous attempt. Writes
+// which fail with ErrNotPrimary while Run is reconnecting are retried too.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	MaxAttempts int
+	// Backoff is the delay between attempts; its MaxAttempts is ignored. The
+	// fields which are not set, or which are invalid, are taken from
+	// DefaultRetryPolicy.
+	Backoff Backoff
+	// Codes are the gRPC codes which are retried.
+	Codes []codes.Code
+	// AttemptFn, if set, is called after every attempt.
+	AttemptFn func(WriteAttempt)
+}
+
+// DefaultRetryPolicy retries writes which fail with UNAVAILABLE, e.g. while
+// the switch agent restarts.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	Backoff: Backoff{
+		BaseDelay:  100 * time.Millisecond,
+		Multiplier: 1.6,
+		Jitter:     0.2,
+		MaxDelay:   5 * time.Second,
+	},
+	Codes: []codes.Code{codes.Unavailable},
+}
+
+// WriteAttempt describes the outcome of an attempt, see RetryPolicy.AttemptFn.
+type WriteAttempt struct {
+	Attempt int // 0 for the first attempt
+	Updates []*p4_v1.Update
+	Err     error // nil if the attempt succeeded
+	// Retry is true if there will be another attempt, after Delay.
+	Retry bool
+	Delay time.Duration
+}
+
+// WithWriteRetry sets the retry policy for writes. Without it, writes are not
+// retried.
+func WithWriteRetry(policy RetryPolicy) func(*ClientOptions) {
+	policy.Backoff = policy.Backoff.withDefaults(DefaultRetryPolicy.Backoff)
+	return func(options *ClientOptions) {
+		options.WriteRetryPolicy = &policy
+	}
+}
+
+func (p *RetryPolicy) retryable(err error) bool {
+	for _, code := range p.Codes {
+		if status.Code(err) == code || errorCode(err) == code {
+			return true
+		}
+	}
+	return false
+}
+
+// retryWrite calls write according to WriteRetryPolicy.
+func (c *Client) retryWrite(ctx context.Context, updates []*p4_v1.Update, write func() error) error {
+	policy := c.WriteRetryPolicy
+	if policy == nil {
+		return write()
+	}
+	// WriteRetryPolicy may also have been set without WithWriteRetry
+	backoff := policy.Backoff.withDefaults(DefaultRetryPolicy.Backoff)
+	for attempt := 0; ; attempt++ {
+		err := write()
+		if err != nil && attempt > 0 {
+			err = c.idempotentRetryError(ctx, updates, err)
+		}
+		retry := err != nil && attempt+1 < policy.MaxAttempts &&
+			(policy.retryable(err) || err == ErrNotPrimary && c.arbitrationPending())
+		var delay time.Duration
+		if retry {
+			delay = backoff.delay(attempt)
+		}
+		if err != nil {
+			log.Debugf("Write attempt %d failed: %v", attempt, err)
+		}
+		if policy.AttemptFn != nil {
+			policy.AttemptFn(WriteAttempt{
+				Attempt: attempt,
+				Updates: updates,
+				Err:     err,
+				Retry:   retry,
+				Delay:   delay,
+			})
+		}
+		if !retry {
+			return err
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
+
+// idempotentRetryError returns the error for a retried write, ignoring the
+// failures which mean that the update was applied by a previous attempt.
+func (c *Client) idempotentRetryError(ctx context.Context, updates []*p4_v1.Update, err error) error {
+	if writeErr, ok := err.(*WriteError); ok {
+		for _, u := range writeErr.Failed() {
+			if c.appliedByPreviousAttempt(ctx, u.Update, u.Code()) {
+				u.Err = &p4_v1.Error{CanonicalCode: int32(codes.OK)}
+			}
+		}
+		if len(writeErr.Failed()) == 0 {
+			return nil
+		}
+		return writeErr
+	}
+	if len(updates) == 1 && c.appliedByPreviousAttempt(ctx, updates[0], errorCode(err)) {
+		return nil
+	}
+	return err
+}
+
+func (c *Client) appliedByPreviousAttempt(ctx context.Context, update *p4_v1.Update, code codes.Code) bool {
+	switch {
+	case update.Type == p4_v1.Update_DELETE && code == codes.NotFound:
+		return true
+	case update.Type == p4_v1.Update_INSERT && code == codes.AlreadyExists:
+		existing, err := c.ReadEntitySingle(ctx, entityKey(update.Entity))
+		return err == nil && proto.Equal(normalizeEntity(existing, update.Entity), normalizeEntity(update.Entity, update.Entity))
+	default:
+		return false
+	}
+}
+
+// normalizeEntity returns a copy of entity which can be compared with the
+// written entity: the bytestrings are in canonical form, as the server may
+// return them that way, and the fields which the server populates when
+// reading are cleared.
+func normalizeEntity(entity, written *p4_v1.Entity) *p4_v1.Entity {
+	normalized := proto.Clone(entity).(*p4_v1.Entity)
+	if entry := normalized.GetTableEntry(); entry != nil {
+		entry.TimeSinceLastHit = nil
+		writtenEntry := written.GetTableEntry()
+		if writtenEntry.GetCounterData() == nil {
+			entry.CounterData = nil
+		}
+		if writtenEntry.GetMeterConfig() == nil {
+			entry.MeterConfig = nil
+		}
+		if writtenEntry.GetMeterCounterData() == nil {
+			entry.MeterCounterData = nil
+		}
+	}
+	canonicalBytestrings(normalized.ProtoReflect())
+	return normalized
+}
+
+// canonicalBytestrings converts all the bytes fields of m to canonical form,
+// recursively.
+func canonicalBytestrings(m protoreflect.Message) {
+	var bytesFields []protoreflect.FieldDescriptor
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsMap():
+		case fd.IsList():
+			list := v.List()
+			for idx := 0; idx < list.Len(); idx++ {
+				switch fd.Kind() {
+				case protoreflect.MessageKind:
+					canonicalBytestrings(list.Get(idx).Message())
+				case protoreflect.BytesKind:
+					list.Set(idx, protoreflect.ValueOfBytes(conversion.ToCanonicalBytestring(list.Get(idx).Bytes())))
+				}
+			}
+		case fd.Kind() == protoreflect.MessageKind:
+			canonicalBytestrings(v.Message())
+		case fd.Kind() == protoreflect.BytesKind:
+			bytesFields = append(bytesFields, fd)
+		}
+		return true
+	})
+	// the fields cannot be set while ranging over m
+	for _, fd := range bytesFields {
+		m.Set(fd, protoreflect.ValueOfBytes(conversion.ToCanonicalBytestring(m.Get(fd).Bytes())))
+	}
+}
@@ -0,0 +1,161 @@
+package client
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	code "google.golang.org/genproto/googleapis/rpc/code"
+
+	p4_v1 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+// TestWriteRetry ensures that transient errors are retried, and that the errors caused by a
+// previous attempt having been applied are ignored.
+func TestWriteRetry(t *testing.T) {
+	entry := &p4_v1.TableEntry{TableId: 1, Priority: 10, Action: &p4_v1.TableAction{
+		Type: &p4_v1.TableAction_Action{Action: &p4_v1.Action{ActionId: 2}},
+	}}
+	existing := entry
+	attempts := 0
+	p4RtClient := &fakeP4RuntimeClient{
+		writeFn: func(ctx context.Context, in *p4_v1.WriteRequest, opts ...grpc.CallOption) (*p4_v1.WriteResponse, error) {
+			attempts++
+			if attempts == 1 {
+				return nil, status.Error(codes.Unavailable, "switch restarting")
+			}
+			if in.Updates[0].Type == p4_v1.Update_INSERT {
+				return nil, status.Error(codes.AlreadyExists, "entry exists")
+			}
+			return nil, status.Error(codes.NotFound, "no such entry")
+		},
+		readFn: func(ctx context.Context, in *p4_v1.ReadRequest, opts ...grpc.CallOption) (p4_v1.P4Runtime_ReadClient, error) {
+			assert.Nil(t, in.Entities[0].GetTableEntry().Action)
+			done := false
+			return &fakeP4RuntimeReadClient{
+				recvFn: func() (*p4_v1.ReadResponse, error) {
+					if done {
+						return nil, io.EOF
+					}
+					done = true
+					return &p4_v1.ReadResponse{Entities: []*p4_v1.Entity{
+						{Entity: &p4_v1.Entity_TableEntry{TableEntry: existing}},
+					}}, nil
+				},
+			}, nil
+		},
+	}
+	fakeClient := newTestClient(p4RtClient, nil)
+	policy := DefaultRetryPolicy
+	policy.Backoff = Backoff{BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	var outcomes []WriteAttempt
+	policy.AttemptFn = func(attempt WriteAttempt) {
+		outcomes = append(outcomes, attempt)
+	}
+	WithWriteRetry(policy)(&fakeClient.ClientOptions)
+	ctx := context.Background()
+
+	assert.NoError(t, fakeClient.InsertTableEntry(ctx, entry))
+	if assert.Len(t, outcomes, 2) {
+		assert.Equal(t, codes.Unavailable, status.Code(outcomes[0].Err))
+		assert.True(t, outcomes[0].Retry)
+		assert.NoError(t, outcomes[1].Err)
+		assert.False(t, outcomes[1].Retry)
+	}
+
+	// the server returns the entry with canonical bytestrings and the fields it populates
+	attempts = 0
+	entry.Match = []*p4_v1.FieldMatch{{
+		FieldId:        1,
+		FieldMatchType: &p4_v1.FieldMatch_Exact_{Exact: &p4_v1.FieldMatch_Exact{Value: []byte{0x00, 0x01}}},
+	}}
+	existing = &p4_v1.TableEntry{
+		TableId:  1,
+		Priority: 10,
+		Match: []*p4_v1.FieldMatch{{
+			FieldId:        1,
+			FieldMatchType: &p4_v1.FieldMatch_Exact_{Exact: &p4_v1.FieldMatch_Exact{Value: []byte{0x01}}},
+		}},
+		Action:           entry.Action,
+		TimeSinceLastHit: &p4_v1.TableEntry_IdleTimeout{ElapsedNs: 1000},
+	}
+	assert.NoError(t, fakeClient.InsertTableEntry(ctx, entry))
+
+	// the existing entry has a different action, so the insert did not succeed
+	attempts = 0
+	existing = &p4_v1.TableEntry{TableId: 1, Priority: 10}
+	assert.True(t, IsAlreadyExists(fakeClient.InsertTableEntry(ctx, entry)))
+
+	attempts = 0
+	assert.NoError(t, fakeClient.DeleteTableEntry(ctx, entry))
+
+	// the first attempt is never considered as applied
+	attempts = 1
+	assert.True(t, IsNotFound(fakeClient.DeleteTableEntry(ctx, entry)))
+}
+
+// TestWriteRetryDefaultBackoff ensures that a policy without a Backoff does not retry without
+// delay.
+func TestWriteRetryDefaultBackoff(t *testing.T) {
+	p4RtClient := &fakeP4RuntimeClient{
+		writeFn: func(ctx context.Context, in *p4_v1.WriteRequest, opts ...grpc.CallOption) (*p4_v1.WriteResponse, error) {
+			return nil, status.Error(codes.Unavailable, "switch restarting")
+		},
+	}
+	fakeClient := newTestClient(p4RtClient, nil)
+	WithWriteRetry(RetryPolicy{MaxAttempts: 2, Codes: []codes.Code{codes.Unavailable}})(&fakeClient.ClientOptions)
+	assert.Equal(t, DefaultRetryPolicy.Backoff.MaxDelay, fakeClient.WriteRetryPolicy.Backoff.MaxDelay)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var delays []time.Duration
+	fakeClient.WriteRetryPolicy.AttemptFn = func(attempt WriteAttempt) {
+		delays = append(delays, attempt.Delay)
+		// do not wait for the delay
+		cancel()
+	}
+	assert.Equal(t, codes.Unavailable, status.Code(fakeClient.InsertTableEntry(ctx, &p4_v1.TableEntry{})))
+	require.Len(t, delays, 1)
+	assert.Greater(t, int64(delays[0]), int64(0))
+}
+
+// TestWriteRetryNotPrimary ensures that writes are retried while Run waits for the arbitration on
+// a new stream, but not when the client is a backup.
+func TestWriteRetryNotPrimary(t *testing.T) {
+	attempts := 0
+	p4RtClient := &fakeP4RuntimeClient{
+		writeFn: func(ctx context.Context, in *p4_v1.WriteRequest, opts ...grpc.CallOption) (*p4_v1.WriteResponse, error) {
+			attempts++
+			return &p4_v1.WriteResponse{}, nil
+		},
+	}
+	fakeClient := newTestClient(p4RtClient, nil)
+	policy := DefaultRetryPolicy
+	policy.Backoff = Backoff{BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	policy.AttemptFn = func(attempt WriteAttempt) {
+		// the stream is up again
+		fakeClient.setArbitration(arbitrationResponse(code.Code_OK).GetArbitration())
+	}
+	WithWriteRetry(policy)(&fakeClient.ClientOptions)
+	require.NoError(t, fakeClient.startRun(func() {}))
+	ctx := context.Background()
+	entry := &p4_v1.TableEntry{TableId: 1}
+
+	assert.NoError(t, fakeClient.InsertTableEntry(ctx, entry))
+	assert.Equal(t, 1, attempts)
+
+	fakeClient.setArbitration(arbitrationResponse(code.Code_ALREADY_EXISTS).GetArbitration())
+	var outcomes []WriteAttempt
+	fakeClient.WriteRetryPolicy.AttemptFn = func(attempt WriteAttempt) {
+		outcomes = append(outcomes, attempt)
+	}
+	assert.Equal(t, ErrNotPrimary, fakeClient.InsertTableEntry(ctx, entry))
+	assert.Len(t, outcomes, 1)
+	assert.Equal(t, 1, attempts)
+}
@@ -0,0 +1,161 @@
+package client
+
+import (
+	"context"
+	"sync/atomic"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	p4_v1 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+const (
+	defaultSendQueueSize = 1000
+)
+
+var (
+	// ErrSendQueueFull is returned when a message cannot be queued for the
+	// StreamChannel because the queue is full and SendQueuePolicy is
+	// OverflowDropNewest.
+	ErrSendQueueFull = status.Error(codes.ResourceExhausted, "StreamChannel send queue is full")
+	// ErrSendQueueDropped is reported by the Result of a queued message which
+	// was discarded to make room for a newer one, with OverflowDropOldest.
+	ErrSendQueueDropped = status.Error(codes.ResourceExhausted, "message dropped from the StreamChannel send queue")
+	// ErrStreamClosed is reported by the Result of messages which were queued
+	// for the StreamChannel but could not be sent before Run returned.
+	ErrStreamClosed = status.Error(codes.Unavailable, "StreamChannel closed before the message could be sent")
+)
+
+// WithSendQueue sets the size of the StreamChannel send queue, used for
+// PacketOuts and DigestListAcks, and what happens when it is full:
+//
+//	OverflowBlock      the caller waits for room in the queue, or for its context to be done
+//	OverflowDropNewest the caller gets ErrSendQueueFull immediately
+//	OverflowDropOldest the oldest queued message is discarded, with ErrSendQueueDropped
+func WithSendQueue(size int, policy OverflowPolicy) func(*ClientOptions) {
+	return func(options *ClientOptions) {
+		options.SendQueueSize = size
+		options.SendQueuePolicy = policy
+	}
+}
+
+// streamRequest is a message queued for the StreamChannel; result completes
+// once the message has been sent on the stream, or has failed to. generation,
+// if not 0, is the digestTracker generation of the stream for which the
+// message is meant.
+type streamRequest struct {
+	m          *p4_v1.StreamMessageRequest
+	result     *Result
+	generation uint64
+}
+
+type SendQueueStats struct {
+	Queued     int    // messages currently waiting in the queue
+	Capacity   int    // size of the queue
+	Sent       uint64 // messages successfully sent on the stream
+	Dropped    uint64 // messages discarded with OverflowDropOldest, or when Run returned
+	Rejected   uint64 // messages not queued with OverflowDropNewest
+	SendErrors uint64 // messages for which stream.Send failed
+}
+
+type sendQueueCounters struct {
+	sent       uint64
+	dropped    uint64
+	rejected   uint64
+	sendErrors uint64
+}
+
+// SendQueueStats returns the counters of the StreamChannel send queue.
+func (c *Client) SendQueueStats() SendQueueStats {
+	return SendQueueStats{
+		Queued:     len(c.streamSendCh),
+		Capacity:   cap(c.streamSendCh),
+		Sent:       atomic.LoadUint64(&c.sendStats.sent),
+		Dropped:    atomic.LoadUint64(&c.sendStats.dropped),
+		Rejected:   atomic.LoadUint64(&c.sendStats.rejected),
+		SendErrors: atomic.LoadUint64(&c.sendStats.sendErrors),
+	}
+}
+
+// SendStreamMessage queues m for the StreamChannel, according to
+// SendQueuePolicy. The returned Result completes when m has been sent by Run,
+// and reports the error if it could not be sent. Errors sent back by the
+// server in a StreamError are not reported, see SendPacketOutAsync.
+func (c *Client) SendStreamMessage(ctx context.Context, m *p4_v1.StreamMessageRequest) (*Result, error) {
+	req := &streamRequest{m: m, result: newResult()}
+	if err := c.enqueue(ctx, req); err != nil {
+		return nil, err
+	}
+	return req.result, nil
+}
+
+func (c *Client) enqueue(ctx context.Context, req *streamRequest) error {
+	switch c.SendQueuePolicy {
+	case OverflowDropNewest:
+		select {
+		case c.streamSendCh <- req:
+		default:
+			atomic.AddUint64(&c.sendStats.rejected, 1)
+			return ErrSendQueueFull
+		}
+	case OverflowDropOldest:
+		for {
+			select {
+			case c.streamSendCh <- req:
+				return nil
+			default:
+			}
+			select {
+			case old := <-c.streamSendCh:
+				c.dropStreamRequest(old, ErrSendQueueDropped)
+			default:
+			}
+		}
+	default:
+		select {
+		case c.streamSendCh <- req:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (c *Client) sendStreamRequest(stream p4_v1.P4Runtime_StreamChannelClient, req *streamRequest) {
+	if c.digests.stale(req.generation) {
+		c.dropStreamRequest(req, ErrStreamClosed)
+		return
+	}
+	if err := stream.Send(req.m); err != nil {
+		atomic.AddUint64(&c.sendStats.sendErrors, 1)
+		log.Debugf("Cannot send message on StreamChannel: %v", err)
+		err = &StreamChannelError{Op: "send", Err: err}
+		req.result.resolve(err)
+		c.streamErrors.cancel(req.m, err)
+		return
+	}
+	atomic.AddUint64(&c.sendStats.sent, 1)
+	req.result.resolve(nil)
+	c.streamErrors.sent(req.m)
+}
+
+func (c *Client) dropStreamRequest(req *streamRequest, err error) {
+	atomic.AddUint64(&c.sendStats.dropped, 1)
+	req.result.resolve(err)
+	c.streamErrors.cancel(req.m, err)
+}
+
+// drainSendQueue discards the messages which are still queued once Run is
+// done, so that they are not sent on a later stream.
+func (c *Client) drainSendQueue() {
+	for {
+		select {
+		case req := <-c.streamSendCh:
+			c.dropStreamRequest(req, ErrStreamClosed)
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	p4_v1 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+func TestSendQueue(t *testing.T) {
+	ctx := context.Background()
+	newMessage := func(listID uint64) *p4_v1.StreamMessageRequest {
+		return &p4_v1.StreamMessageRequest{
+			Update: &p4_v1.StreamMessageRequest_DigestAck{DigestAck: &p4_v1.DigestListAck{DigestId: 1, ListId: listID}},
+		}
+	}
+	newQueueClient := func(policy OverflowPolicy) *Client {
+		fakeClient := newTestClient(&fakeP4RuntimeClient{}, nil)
+		fakeClient.SendQueuePolicy = policy
+		fakeClient.streamSendCh = make(chan *streamRequest, 1)
+		return fakeClient
+	}
+
+	t.Run("DropNewest", func(t *testing.T) {
+		fakeClient := newQueueClient(OverflowDropNewest)
+		_, err := fakeClient.SendStreamMessage(ctx, newMessage(1))
+		require.NoError(t, err)
+		_, err = fakeClient.SendStreamMessage(ctx, newMessage(2))
+		assert.Equal(t, ErrSendQueueFull, err)
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+		assert.Equal(t, SendQueueStats{Queued: 1, Capacity: 1, Rejected: 1}, fakeClient.SendQueueStats())
+	})
+
+	t.Run("DropOldest", func(t *testing.T) {
+		fakeClient := newQueueClient(OverflowDropOldest)
+		result1, err := fakeClient.SendStreamMessage(ctx, newMessage(1))
+		require.NoError(t, err)
+		_, err = fakeClient.SendStreamMessage(ctx, newMessage(2))
+		require.NoError(t, err)
+		assert.Equal(t, ErrSendQueueDropped, result1.Err())
+		assert.Equal(t, uint64(2), (<-fakeClient.streamSendCh).m.GetDigestAck().GetListId())
+		assert.Equal(t, SendQueueStats{Capacity: 1, Dropped: 1}, fakeClient.SendQueueStats())
+	})
+
+	t.Run("SendError", func(t *testing.T) {
+		fakeClient := newQueueClient(OverflowBlock)
+		stream := &fakeP4RuntimeStreamChannelClient{
+			sendFn: func(m *p4_v1.StreamMessageRequest) error {
+				if m.GetDigestAck().GetListId() == 2 {
+					return status.Error(codes.Unavailable, "connection reset")
+				}
+				return nil
+			},
+		}
+		for listID := uint64(1); listID <= 2; listID++ {
+			result, err := fakeClient.SendStreamMessage(ctx, newMessage(listID))
+			require.NoError(t, err)
+			fakeClient.sendStreamRequest(stream, <-fakeClient.streamSendCh)
+			err = result.Err()
+			if listID == 1 {
+				assert.NoError(t, err)
+				continue
+			}
+			var streamErr *StreamChannelError
+			require.True(t, errors.As(err, &streamErr))
+			assert.Equal(t, "send", streamErr.Op)
+			assert.Equal(t, codes.Unavailable, status.Code(err))
+		}
+		assert.Equal(t, SendQueueStats{Capacity: 1, Sent: 1, SendErrors: 1}, fakeClient.SendQueueStats())
+	})
+}
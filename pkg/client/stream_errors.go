@@ -0,0 +1,269 @@
+package client
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	//nolint:staticcheck // SA1019 To be resolved later
+	//lint:ignore SA1019 This line added for support golint version of VSC
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/any"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	p4_v1 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+const (
+	defaultStreamErrorWindow = 1 * time.Second
+)
+
+// StreamError is a decoded p4_v1.StreamError, sent by the server when it
+// cannot process a stream message. At most one of PacketOut, DigestListAck and
+// Other is set, and identifies the message which caused the error.
+type StreamError struct {
+	Code          codes.Code // canonical error code
+	Message       string
+	Space         string // error space for Code, when it is target-specific
+	TargetCode    int32  // target-specific error code
+	PacketOut     *p4_v1.PacketOut
+	DigestListAck *p4_v1.DigestListAck
+	Other         *any.Any
+}
+
+func NewStreamError(streamError *p4_v1.StreamError) *StreamError {
+	return &StreamError{
+		Code:          codes.Code(streamError.CanonicalCode),
+		Message:       streamError.Message,
+		Space:         streamError.Space,
+		TargetCode:    streamError.Code,
+		PacketOut:     streamError.GetPacketOut().GetPacketOut(),
+		DigestListAck: streamError.GetDigestListAck().GetDigestListAck(),
+		Other:         streamError.GetOther().GetOther(),
+	}
+}
+
+func (e *StreamError) Error() string {
+	s := fmt.Sprintf("stream error %v: %s", e.Code, e.Message)
+	switch {
+	case e.PacketOut != nil:
+		s += " (PacketOut)"
+	case e.DigestListAck != nil:
+		s += fmt.Sprintf(" (DigestListAck for list %d of digest %d)", e.DigestListAck.ListId, e.DigestListAck.DigestId)
+	}
+	return s
+}
+
+func (e *StreamError) GRPCStatus() *status.Status {
+	return status.New(e.Code, e.Message)
+}
+
+type pendingPacketOut struct {
+	packet *p4_v1.PacketOut
+	result *Result
+	window time.Duration
+	hash   uint64
+	elem   *list.Element // in streamErrorTracker.packetOutsByHash[hash]
+}
+
+func packetOutHash(packet *p4_v1.PacketOut) uint64 {
+	h := fnv.New64a()
+	h.Write(packet.Payload)
+	return h.Sum64()
+}
+
+type digestAckKey struct {
+	digestID uint32
+	listID   uint64
+}
+
+type pendingDigestAck struct {
+	result *Result
+	window time.Duration
+}
+
+// streamErrorTracker matches StreamErrors with the PacketOut and DigestListAck
+// messages sent by the asynchronous methods of the client. The P4Runtime
+// server never confirms that a stream message was processed successfully, so
+// a message is considered successful if no StreamError is received for it
+// within a time window, which starts when the message is sent on the stream.
+type streamErrorTracker struct {
+	mutex sync.Mutex
+	// packetOuts indexes the pending PacketOuts by the message sent, and
+	// packetOutsByHash by the hash of their payload, oldest first, to match
+	// the PacketOut echoed in a StreamError
+	packetOuts       map[*p4_v1.PacketOut]*pendingPacketOut
+	packetOutsByHash map[uint64]*list.List
+	digestAcks       map[digestAckKey]*pendingDigestAck
+}
+
+func newStreamErrorTracker() *streamErrorTracker {
+	return &streamErrorTracker{
+		packetOuts:       make(map[*p4_v1.PacketOut]*pendingPacketOut),
+		packetOutsByHash: make(map[uint64]*list.List),
+		digestAcks:       make(map[digestAckKey]*pendingDigestAck),
+	}
+}
+
+func (t *streamErrorTracker) trackPacketOut(packet *p4_v1.PacketOut, window time.Duration) *Result {
+	pending := &pendingPacketOut{packet: packet, result: newResult(), window: window, hash: packetOutHash(packet)}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	bucket, ok := t.packetOutsByHash[pending.hash]
+	if !ok {
+		bucket = list.New()
+		t.packetOutsByHash[pending.hash] = bucket
+	}
+	pending.elem = bucket.PushBack(pending)
+	t.packetOuts[packet] = pending
+	return pending.result
+}
+
+// removePacketOut must be called with the mutex held. pending may already
+// have been removed, e.g. when its window expires after a match.
+func (t *streamErrorTracker) removePacketOut(pending *pendingPacketOut) {
+	if t.packetOuts[pending.packet] != pending {
+		return
+	}
+	delete(t.packetOuts, pending.packet)
+	bucket := t.packetOutsByHash[pending.hash]
+	bucket.Remove(pending.elem)
+	if bucket.Len() == 0 {
+		delete(t.packetOutsByHash, pending.hash)
+	}
+}
+
+func (t *streamErrorTracker) trackDigestAck(ack *p4_v1.DigestListAck, window time.Duration) *Result {
+	key := digestAckKey{ack.DigestId, ack.ListId}
+	pending := &pendingDigestAck{result: newResult(), window: window}
+	t.mutex.Lock()
+	if old, ok := t.digestAcks[key]; ok {
+		// only the latest ack for a list can be matched
+		old.result.resolve(nil)
+	}
+	t.digestAcks[key] = pending
+	t.mutex.Unlock()
+	return pending.result
+}
+
+// sent starts the time window of the pending operation for m, which has just
+// been sent on the stream.
+func (t *streamErrorTracker) sent(m *p4_v1.StreamMessageRequest) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	switch update := m.Update.(type) {
+	case *p4_v1.StreamMessageRequest_Packet:
+		if pending, ok := t.packetOuts[update.Packet]; ok {
+			time.AfterFunc(pending.window, func() {
+				t.mutex.Lock()
+				t.removePacketOut(pending)
+				t.mutex.Unlock()
+				pending.result.resolve(nil)
+			})
+		}
+	case *p4_v1.StreamMessageRequest_DigestAck:
+		key := digestAckKey{update.DigestAck.DigestId, update.DigestAck.ListId}
+		if pending, ok := t.digestAcks[key]; ok {
+			time.AfterFunc(pending.window, func() {
+				t.mutex.Lock()
+				if t.digestAcks[key] == pending {
+					delete(t.digestAcks, key)
+				}
+				t.mutex.Unlock()
+				pending.result.resolve(nil)
+			})
+		}
+	}
+}
+
+// cancel resolves the pending operation for m, which was not sent, with err.
+func (t *streamErrorTracker) cancel(m *p4_v1.StreamMessageRequest, err error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	switch update := m.Update.(type) {
+	case *p4_v1.StreamMessageRequest_Packet:
+		if pending, ok := t.packetOuts[update.Packet]; ok {
+			t.removePacketOut(pending)
+			pending.result.resolve(err)
+		}
+	case *p4_v1.StreamMessageRequest_DigestAck:
+		key := digestAckKey{update.DigestAck.DigestId, update.DigestAck.ListId}
+		if pending, ok := t.digestAcks[key]; ok {
+			delete(t.digestAcks, key)
+			pending.result.resolve(err)
+		}
+	}
+}
+
+// match resolves the pending operation which caused streamError, if any, and
+// returns false if there is none.
+func (t *streamErrorTracker) match(streamError *StreamError) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	switch {
+	case streamError.PacketOut != nil:
+		bucket, ok := t.packetOutsByHash[packetOutHash(streamError.PacketOut)]
+		if !ok {
+			return false
+		}
+		for elem := bucket.Front(); elem != nil; elem = elem.Next() {
+			pending := elem.Value.(*pendingPacketOut)
+			if proto.Equal(pending.packet, streamError.PacketOut) {
+				t.removePacketOut(pending)
+				pending.result.resolve(streamError)
+				return true
+			}
+		}
+	case streamError.DigestListAck != nil:
+		key := digestAckKey{streamError.DigestListAck.DigestId, streamError.DigestListAck.ListId}
+		if pending, ok := t.digestAcks[key]; ok {
+			delete(t.digestAcks, key)
+			pending.result.resolve(streamError)
+			return true
+		}
+	}
+	return false
+}
+
+// SendPacketOutAsync is like SendPacketOut, but the returned Result reports
+// the StreamError sent by the server for this PacketOut, if any. The Result
+// completes successfully if no error is received within StreamErrorWindow
+// after the PacketOut is sent on the stream.
+func (c *Client) SendPacketOutAsync(ctx context.Context, payload []byte, metadata map[string][]byte) (*Result, error) {
+	packet, err := c.NewPacketOut(payload, metadata)
+	if err != nil {
+		return nil, err
+	}
+	m := &p4_v1.StreamMessageRequest{
+		Update: &p4_v1.StreamMessageRequest_Packet{Packet: packet},
+	}
+	result := c.streamErrors.trackPacketOut(packet, c.StreamErrorWindow)
+	if _, err := c.SendStreamMessage(ctx, m); err != nil {
+		c.streamErrors.cancel(m, err)
+		return nil, err
+	}
+	return result, nil
+}
+
+// AckDigestListAsync is like AckDigestList, but the returned Result reports
+// the StreamError sent by the server for this ack, if any. The Result
+// completes successfully if no error is received within StreamErrorWindow
+// after the ack is sent on the stream.
+func (c *Client) AckDigestListAsync(ctx context.Context, digestList *p4_v1.DigestList) (*Result, error) {
+	ack := &p4_v1.DigestListAck{
+		DigestId: digestList.DigestId,
+		ListId:   digestList.ListId,
+	}
+	result := c.streamErrors.trackDigestAck(ack, c.StreamErrorWindow)
+	if _, err := c.sendDigestListAck(ctx, digestList, 0); err != nil {
+		c.streamErrors.cancel(&p4_v1.StreamMessageRequest{
+			Update: &p4_v1.StreamMessageRequest_DigestAck{DigestAck: ack},
+		}, err)
+		return nil, err
+	}
+	return result, nil
+}
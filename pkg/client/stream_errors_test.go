@@ -0,0 +1,155 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	p4_v1 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+// sendQueued sends the messages waiting in the send queue of c on stream, like Run does.
+func sendQueued(c *Client, stream p4_v1.P4Runtime_StreamChannelClient) {
+	for {
+		select {
+		case req := <-c.streamSendCh:
+			c.sendStreamRequest(stream, req)
+		default:
+			return
+		}
+	}
+}
+
+func TestStreamErrorMatch(t *testing.T) {
+	ctx := context.Background()
+	fakeClient := newTestClient(&fakeP4RuntimeClient{}, newPacketMetadataP4Info())
+	fakeClient.StreamErrorWindow = time.Hour
+	stream := &fakeP4RuntimeStreamChannelClient{}
+
+	packetResult, err := fakeClient.SendPacketOutAsync(ctx, []byte{'\x01'}, nil)
+	require.NoError(t, err)
+	otherPacketResult, err := fakeClient.SendPacketOutAsync(ctx, []byte{'\x02'}, nil)
+	require.NoError(t, err)
+	ackResult, err := fakeClient.AckDigestListAsync(ctx, &p4_v1.DigestList{DigestId: 1, ListId: 2})
+	require.NoError(t, err)
+	sendQueued(fakeClient, stream)
+
+	packetError := NewStreamError(&p4_v1.StreamError{
+		CanonicalCode: int32(codes.InvalidArgument),
+		Message:       "bad packet",
+		Details: &p4_v1.StreamError_PacketOut{PacketOut: &p4_v1.PacketOutError{
+			PacketOut: &p4_v1.PacketOut{Payload: []byte{'\x01'}},
+		}},
+	})
+	// another PacketOut with the same payload, which is matched after the first one
+	sameResult, err := fakeClient.SendPacketOutAsync(ctx, []byte{'\x01'}, nil)
+	require.NoError(t, err)
+	sendQueued(fakeClient, stream)
+	assert.True(t, fakeClient.streamErrors.match(packetError))
+	assert.Equal(t, packetError, packetResult.Err())
+	select {
+	case <-sameResult.Done():
+		assert.Fail(t, "the second PacketOut with the same payload should still be pending")
+	default:
+	}
+	assert.True(t, fakeClient.streamErrors.match(packetError))
+	assert.Equal(t, packetError, sameResult.Err())
+	assert.Equal(t, codes.InvalidArgument, status.Code(packetResult.Err()))
+	// the error has already been matched
+	assert.False(t, fakeClient.streamErrors.match(packetError))
+	select {
+	case <-otherPacketResult.Done():
+		assert.Fail(t, "the other PacketOut should still be pending")
+	default:
+	}
+
+	ackError := NewStreamError(&p4_v1.StreamError{
+		CanonicalCode: int32(codes.NotFound),
+		Details: &p4_v1.StreamError_DigestListAck{DigestListAck: &p4_v1.DigestListAckError{
+			DigestListAck: &p4_v1.DigestListAck{DigestId: 1, ListId: 3},
+		}},
+	})
+	assert.False(t, fakeClient.streamErrors.match(ackError), "the error is for another list")
+	ackError.DigestListAck.ListId = 2
+	assert.True(t, fakeClient.streamErrors.match(ackError))
+	assert.Equal(t, ackError, ackResult.Err())
+	assert.Len(t, fakeClient.streamErrors.packetOuts, 1)
+	assert.Len(t, fakeClient.streamErrors.packetOutsByHash, 1)
+}
+
+func TestStreamErrorCancel(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("SendFailure", func(t *testing.T) {
+		fakeClient := newTestClient(&fakeP4RuntimeClient{}, newPacketMetadataP4Info())
+		fakeClient.StreamErrorWindow = time.Hour
+		sendErr := errors.New("stream is broken")
+		stream := &fakeP4RuntimeStreamChannelClient{
+			sendFn: func(*p4_v1.StreamMessageRequest) error {
+				return sendErr
+			},
+		}
+		packetResult, err := fakeClient.SendPacketOutAsync(ctx, []byte{'\x01'}, nil)
+		require.NoError(t, err)
+		ackResult, err := fakeClient.AckDigestListAsync(ctx, &p4_v1.DigestList{DigestId: 1, ListId: 1})
+		require.NoError(t, err)
+		sendQueued(fakeClient, stream)
+		assert.Equal(t, &StreamChannelError{Op: "send", Err: sendErr}, packetResult.Err())
+		assert.Equal(t, &StreamChannelError{Op: "send", Err: sendErr}, ackResult.Err())
+		assert.Empty(t, fakeClient.streamErrors.packetOuts)
+	assert.Empty(t, fakeClient.streamErrors.packetOutsByHash)
+		assert.Empty(t, fakeClient.streamErrors.digestAcks)
+	})
+
+	t.Run("QueueFull", func(t *testing.T) {
+		fakeClient := newTestClient(&fakeP4RuntimeClient{}, newPacketMetadataP4Info())
+		fakeClient.SendQueuePolicy = OverflowDropNewest
+		fakeClient.streamSendCh = make(chan *streamRequest)
+		_, err := fakeClient.SendPacketOutAsync(ctx, []byte{'\x01'}, nil)
+		assert.Equal(t, ErrSendQueueFull, err)
+		_, err = fakeClient.AckDigestListAsync(ctx, &p4_v1.DigestList{DigestId: 1, ListId: 1})
+		assert.Equal(t, ErrSendQueueFull, err)
+		assert.Empty(t, fakeClient.streamErrors.packetOuts)
+	assert.Empty(t, fakeClient.streamErrors.packetOutsByHash)
+		assert.Empty(t, fakeClient.streamErrors.digestAcks)
+	})
+}
+
+// TestStreamErrorWindow ensures that the time window for StreamErrors only starts when the message
+// is sent, and that the operation succeeds once it expires.
+func TestStreamErrorWindow(t *testing.T) {
+	ctx := context.Background()
+	fakeClient := newTestClient(&fakeP4RuntimeClient{}, newPacketMetadataP4Info())
+	fakeClient.StreamErrorWindow = 10 * time.Millisecond
+	stream := &fakeP4RuntimeStreamChannelClient{}
+
+	packetResult, err := fakeClient.SendPacketOutAsync(ctx, []byte{'\x01'}, nil)
+	require.NoError(t, err)
+	ackResult, err := fakeClient.AckDigestListAsync(ctx, &p4_v1.DigestList{DigestId: 1, ListId: 1})
+	require.NoError(t, err)
+	time.Sleep(5 * fakeClient.StreamErrorWindow)
+	for _, result := range []*Result{packetResult, ackResult} {
+		select {
+		case <-result.Done():
+			assert.Fail(t, "the window should not start before the message is sent")
+		default:
+		}
+	}
+
+	sendQueued(fakeClient, stream)
+	waitCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	assert.NoError(t, packetResult.Wait(waitCtx))
+	assert.NoError(t, ackResult.Wait(waitCtx))
+	fakeClient.streamErrors.mutex.Lock()
+	defer fakeClient.streamErrors.mutex.Unlock()
+	assert.Empty(t, fakeClient.streamErrors.packetOuts)
+	assert.Empty(t, fakeClient.streamErrors.packetOutsByHash)
+	assert.Empty(t, fakeClient.streamErrors.digestAcks)
+}
@@ -0,0 +1,220 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"sync/atomic"
+
+	p4_v1 "github.com/p4lang/p4runtime/go/p4/v1"
+
+	"github.com/RainyBow/p4runtime-go-client/pkg/util/conversion"
+)
+
+// Event is a message received on the StreamChannel, as delivered to
+// subscribers.
+type Event struct {
+	Type    StreamEventType
+	Message *p4_v1.StreamMessageResponse
+	// PacketIn is the decoded PacketIn for a PacketInEvent, or nil if it
+	// could not be decoded.
+	PacketIn *PacketIn
+	// Digest is the name of the digest for a DigestListEvent.
+	Digest string
+}
+
+// EventFilter selects the events delivered to a subscriber. An empty filter
+// matches every event.
+type EventFilter struct {
+	// Types, if not empty, is the list of event types to deliver.
+	Types []StreamEventType
+	// Digest, if set, only matches DigestLists for the digest with this name.
+	Digest string
+	// PacketMetadata, if not empty, only matches PacketIns which have all
+	// these "packet_in" metadata values.
+	PacketMetadata map[string][]byte
+}
+
+func (f *EventFilter) match(event Event) bool {
+	if len(f.Types) > 0 {
+		found := false
+		for _, t := range f.Types {
+			if t == event.Type {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.Digest != "" && (event.Type != DigestListEvent || event.Digest != f.Digest) {
+		return false
+	}
+	if len(f.PacketMetadata) > 0 {
+		if event.PacketIn == nil {
+			return false
+		}
+		for name, value := range f.PacketMetadata {
+			actual, ok := event.PacketIn.Metadata[name]
+			if !ok || !bytes.Equal(conversion.ToCanonicalBytestring(actual), conversion.ToCanonicalBytestring(value)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+type subscription struct {
+	filter  EventFilter
+	options HandlerOptions
+
+	mutex    sync.RWMutex
+	ch       chan Event
+	done     chan struct{}
+	received uint64
+	dropped  uint64
+}
+
+type SubscriptionStats struct {
+	Received uint64 // events matching the filter
+	Dropped  uint64 // events discarded because the queue was full
+	Queued   int    // events currently waiting in the channel
+}
+
+func (s *subscription) push(ctx context.Context, event Event) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	select {
+	case <-s.done:
+		return
+	default:
+	}
+	atomic.AddUint64(&s.received, 1)
+	switch s.options.Policy {
+	case OverflowDropNewest:
+		select {
+		case s.ch <- event:
+		default:
+			atomic.AddUint64(&s.dropped, 1)
+		}
+	case OverflowDropOldest:
+		for {
+			select {
+			case s.ch <- event:
+				return
+			default:
+			}
+			select {
+			case <-s.ch:
+				atomic.AddUint64(&s.dropped, 1)
+			default:
+			}
+		}
+	default:
+		select {
+		case s.ch <- event:
+		case <-s.done:
+			atomic.AddUint64(&s.dropped, 1)
+		case <-ctx.Done():
+			atomic.AddUint64(&s.dropped, 1)
+		}
+	}
+}
+
+func (s *subscription) stats() SubscriptionStats {
+	return SubscriptionStats{
+		Received: atomic.LoadUint64(&s.received),
+		Dropped:  atomic.LoadUint64(&s.dropped),
+		Queued:   len(s.ch),
+	}
+}
+
+// Subscribe returns a channel on which the events matching filter are
+// delivered, in addition to the Dispatcher handlers and the channels given to
+// Run. Each subscriber has its own queue, sized and managed according to the
+// QueueSize and Policy of the HandlerOptions. By default, events are dropped
+// when the queue is full, which SubscriptionStats reports; with OverflowBlock,
+// a slow subscriber delays every other consumer. Subscriptions remain active
+// across Run restarts. Calling cancel closes the channel; it does not affect
+// the other subscribers.
+func (c *Client) Subscribe(filter EventFilter, optionsModifierFns ...func(*HandlerOptions)) (<-chan Event, func()) {
+	return c.dispatcher.subscribe(filter, optionsModifierFns...)
+}
+
+// SubscriptionStats returns the counters of the subscription whose channel is
+// ch, or false if it does not exist or has been cancelled.
+func (c *Client) SubscriptionStats(ch <-chan Event) (SubscriptionStats, bool) {
+	c.dispatcher.mutex.Lock()
+	defer c.dispatcher.mutex.Unlock()
+	for s := range c.dispatcher.subscriptions {
+		if s.ch == ch {
+			return s.stats(), true
+		}
+	}
+	return SubscriptionStats{}, false
+}
+
+func (d *Dispatcher) subscribe(filter EventFilter, optionsModifierFns ...func(*HandlerOptions)) (<-chan Event, func()) {
+	options := defaultHandlerOptions
+	for _, fn := range optionsModifierFns {
+		fn(&options)
+	}
+	if options.QueueSize < 1 {
+		options.QueueSize = 1
+	}
+	s := &subscription{
+		filter:  filter,
+		options: options,
+		ch:      make(chan Event, options.QueueSize),
+		done:    make(chan struct{}),
+	}
+	d.mutex.Lock()
+	d.subscriptions[s] = struct{}{}
+	d.mutex.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			d.mutex.Lock()
+			delete(d.subscriptions, s)
+			d.mutex.Unlock()
+			// unblock push before waiting for it to return
+			close(s.done)
+			s.mutex.Lock()
+			close(s.ch)
+			s.mutex.Unlock()
+		})
+	}
+	return s.ch, cancel
+}
+
+// publish delivers m to the matching subscribers.
+func (d *Dispatcher) publish(ctx context.Context, m *p4_v1.StreamMessageResponse) {
+	d.mutex.Lock()
+	subscriptions := make([]*subscription, 0, len(d.subscriptions))
+	for s := range d.subscriptions {
+		subscriptions = append(subscriptions, s)
+	}
+	d.mutex.Unlock()
+	if len(subscriptions) == 0 {
+		return
+	}
+
+	event := Event{
+		Type:    streamEventType(m),
+		Message: m,
+	}
+	switch event.Type {
+	case PacketInEvent:
+		// an error is reported to subscribers by a nil PacketIn
+		event.PacketIn, _ = d.client.DecodePacketIn(m.GetPacket())
+	case DigestListEvent:
+		event.Digest = d.client.findDigestById(m.GetDigest().GetDigestId()).GetPreamble().GetName()
+	}
+	for _, s := range subscriptions {
+		if s.filter.match(event) {
+			s.push(ctx, event)
+		}
+	}
+}
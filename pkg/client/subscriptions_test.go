@@ -0,0 +1,74 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	p4_config_v1 "github.com/p4lang/p4runtime/go/p4/config/v1"
+	p4_v1 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+func TestSubscribe(t *testing.T) {
+	p4Info := newPacketMetadataP4Info()
+	p4Info.Digests = []*p4_config_v1.Digest{
+		{Preamble: &p4_config_v1.Preamble{Name: "mac_learn_digest_t", Id: 1}},
+		{Preamble: &p4_config_v1.Preamble{Name: "other_digest_t", Id: 2}},
+	}
+	fakeClient := newTestClient(&fakeP4RuntimeClient{}, p4Info)
+	ctx := context.Background()
+	packetIn := func(port byte) *p4_v1.StreamMessageResponse {
+		return &p4_v1.StreamMessageResponse{
+			Update: &p4_v1.StreamMessageResponse_Packet{Packet: &p4_v1.PacketIn{
+				Payload:  []byte{port},
+				Metadata: []*p4_v1.PacketMetadata{{MetadataId: 1, Value: []byte{port}}},
+			}},
+		}
+	}
+	digestList := func(digestID uint32) *p4_v1.StreamMessageResponse {
+		return &p4_v1.StreamMessageResponse{
+			Update: &p4_v1.StreamMessageResponse_Digest{Digest: &p4_v1.DigestList{DigestId: digestID}},
+		}
+	}
+
+	allCh, cancelAll := fakeClient.Subscribe(EventFilter{})
+	digestCh, cancelDigest := fakeClient.Subscribe(EventFilter{Digest: "mac_learn_digest_t"})
+	defer cancelDigest()
+	portCh, cancelPort := fakeClient.Subscribe(EventFilter{
+		Types:          []StreamEventType{PacketInEvent},
+		PacketMetadata: map[string][]byte{"ingress_port": {'\x00', '\x02'}},
+	}, WithQueueSize(1))
+	defer cancelPort()
+
+	for _, m := range []*p4_v1.StreamMessageResponse{packetIn(1), packetIn(2), digestList(1), digestList(2), packetIn(2)} {
+		assert.False(t, fakeClient.dispatcher.dispatch(ctx, m), "subscribers are not handlers")
+	}
+
+	assert.Len(t, allCh, 5)
+	require.Len(t, digestCh, 1)
+	event := <-digestCh
+	assert.Equal(t, DigestListEvent, event.Type)
+	assert.Equal(t, "mac_learn_digest_t", event.Digest)
+	// the second PacketIn for port 2 was dropped
+	stats, ok := fakeClient.SubscriptionStats(portCh)
+	require.True(t, ok)
+	assert.Equal(t, SubscriptionStats{Received: 2, Dropped: 1, Queued: 1}, stats)
+	require.Len(t, portCh, 1)
+	event = <-portCh
+	require.NotNil(t, event.PacketIn)
+	assert.Equal(t, []byte{'\x02'}, event.PacketIn.Payload)
+
+	cancelAll()
+	cancelAll()
+	_, ok = fakeClient.SubscriptionStats(allCh)
+	assert.False(t, ok)
+	fakeClient.dispatcher.dispatch(ctx, digestList(1))
+	assert.Len(t, digestCh, 1, "cancelling a subscription does not affect the other ones")
+	count := 0
+	for range allCh {
+		count++
+	}
+	assert.Equal(t, 5, count)
+}
@@ -0,0 +1,208 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	p4_config_v1 "github.com/p4lang/p4runtime/go/p4/config/v1"
+	p4_v1 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+// TableReadOptions filters the entries returned by ReadTableEntries, in
+// addition to the match fields.
+type TableReadOptions struct {
+	// Priority, if not 0, only keeps the entries with this priority.
+	Priority int32
+	// Action, if set, only keeps the entries with this direct action.
+	Action string
+	// DefaultEntry reads the default entry of the table instead of the
+	// regular entries. It cannot be combined with match fields or Priority.
+	DefaultEntry bool
+	// CounterData and MeterConfig read the direct counter and meter of each
+	// entry with the entry, in the CounterData and MeterConfig fields. The
+	// table must have a direct counter, respectively a direct meter, in the
+	// P4Info.
+	CounterData bool
+	MeterConfig bool
+}
+
+type fieldFilter struct {
+	field *p4_config_v1.MatchField
+	match MatchInterface
+}
+
+// ReadTableEntries reads the entries of table whose match fields match mfs,
+// which is indexed by match field name, like for NewTableEntry. The fields
+// which are not in mfs are wildcards. A filter applies to the value of the
+// field in the entry, whatever the match kind of the field:
+//   - ExactMatch and OptionalMatch keep the entries with the same value,
+//   - LpmMatch keeps the entries whose value starts with the prefix, e.g. all
+//     the entries for a MAC prefix in an exact match table,
+//   - TernaryMatch keeps the entries whose value is equal under the mask,
+//   - RangeMatch keeps the entries whose value is in the range.
+//
+// The P4Runtime spec does not support partial matches in a ReadRequest, so
+// the entries are streamed from a wildcard read and filtered by the client.
+func (c *Client) ReadTableEntries(ctx context.Context, table string, mfs map[string]MatchInterface, options *TableReadOptions) ([]*p4_v1.TableEntry, error) {
+	p4Table := c.findTable(table)
+	if p4Table == nil {
+		return nil, fmt.Errorf("table %s not found", table)
+	}
+	if options == nil {
+		options = &TableReadOptions{}
+	}
+	// the default entry has no match fields and no priority, so the filters
+	// would never match it
+	if options.DefaultEntry && (len(mfs) > 0 || options.Priority != 0) {
+		return nil, fmt.Errorf("match fields and priority cannot be used to read the default entry of table %s", table)
+	}
+	filters := make([]fieldFilter, 0, len(mfs))
+	for name, mf := range mfs {
+		field := c.findFieldInTable(p4Table, c.matchFieldId(table, name))
+		if field == nil {
+			return nil, fmt.Errorf("match field %s not found in table %s", name, table)
+		}
+		filters = append(filters, fieldFilter{field: field, match: mf})
+	}
+	var actionID uint32
+	if options.Action != "" {
+		if actionID = c.actionId(options.Action); actionID == invalidID {
+			return nil, fmt.Errorf("action %s not found", options.Action)
+		}
+	}
+
+	entry := &p4_v1.TableEntry{
+		TableId:         p4Table.Preamble.Id,
+		IsDefaultAction: options.DefaultEntry,
+	}
+	if options.CounterData {
+		if c.findDirectCounterInTable(p4Table) == nil {
+			return nil, fmt.Errorf("table %s has no direct counter", table)
+		}
+		entry.CounterData = &p4_v1.CounterData{}
+	}
+	if options.MeterConfig {
+		if c.findDirectMeterInTable(p4Table) == nil {
+			return nil, fmt.Errorf("table %s has no direct meter", table)
+		}
+		entry.MeterConfig = &p4_v1.MeterConfig{}
+	}
+	it := c.ReadEntityIterator(ctx, &p4_v1.Entity{
+		Entity: &p4_v1.Entity_TableEntry{TableEntry: entry},
+	})
+	defer it.Close()
+	out := make([]*p4_v1.TableEntry, 0)
+	for it.Next() {
+		readEntry := it.Entity().GetTableEntry()
+		if readEntry == nil {
+			return nil, fmt.Errorf("server returned an entity which is not a table entry!")
+		}
+		if options.Priority != 0 && readEntry.Priority != options.Priority {
+			continue
+		}
+		if actionID != 0 && readEntry.GetAction().GetAction().GetActionId() != actionID {
+			continue
+		}
+		if matchFilters(readEntry, filters) {
+			out = append(out, readEntry)
+		}
+	}
+	// 原样返回err,以便后续可以以GRPC的错误进行处理
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func matchFilters(entry *p4_v1.TableEntry, filters []fieldFilter) bool {
+	for _, filter := range filters {
+		var value []byte
+		found := false
+		for _, fm := range entry.Match {
+			if fm.FieldId == filter.field.Id {
+				value, found = fieldMatchValue(fm)
+				break
+			}
+		}
+		// a field which is missing is a "don't care" match, which cannot be
+		// compared to a value
+		if !found || !filter.matches(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// fieldMatchValue returns the value of fm, which does not exist for ranges.
+func fieldMatchValue(fm *p4_v1.FieldMatch) ([]byte, bool) {
+	switch m := fm.FieldMatchType.(type) {
+	case *p4_v1.FieldMatch_Exact_:
+		return m.Exact.Value, true
+	case *p4_v1.FieldMatch_Lpm:
+		return m.Lpm.Value, true
+	case *p4_v1.FieldMatch_Ternary_:
+		return m.Ternary.Value, true
+	case *p4_v1.FieldMatch_Optional_:
+		return m.Optional.Value, true
+	default:
+		return nil, false
+	}
+}
+
+func (f *fieldFilter) matches(value []byte) bool {
+	// values are compared with the width of the field, as they may or may not
+	// be in canonical form
+	bitwidth := int(f.field.Bitwidth)
+	if bitwidth == 0 {
+		// e.g. a string field
+		bitwidth = len(value) * 8
+	}
+	width := (bitwidth + 7) / 8
+	value = padBytes(value, width)
+	switch m := f.match.(type) {
+	case *ExactMatch:
+		return bytes.Equal(value, padBytes(m.Value, width))
+	case *OptionalMatch:
+		return bytes.Equal(value, padBytes(m.Value, width))
+	case *LpmMatch:
+		prefix := padBytes(m.Value, width)
+		// the prefix length is relative to the width of the field in bits,
+		// which may not be a multiple of 8
+		pLen := int(m.PLen) + width*8 - bitwidth
+		for i := 0; i < width && pLen > 0; i++ {
+			mask := byte(0xff)
+			if pLen < 8 {
+				mask = 0xff << (8 - pLen)
+			}
+			if value[i]&mask != prefix[i]&mask {
+				return false
+			}
+			pLen -= 8
+		}
+		return true
+	case *TernaryMatch:
+		v, mask := padBytes(m.Value, width), padBytes(m.Mask, width)
+		for i := range value {
+			if value[i]&mask[i] != v[i]&mask[i] {
+				return false
+			}
+		}
+		return true
+	case *RangeMatch:
+		return bytes.Compare(value, padBytes(m.Low, width)) >= 0 && bytes.Compare(value, padBytes(m.High, width)) <= 0
+	default:
+		return false
+	}
+}
+
+// padBytes returns v with exactly width bytes, adding leading zeros or
+// removing the leading bytes which do not fit.
+func padBytes(v []byte, width int) []byte {
+	if len(v) >= width {
+		return v[len(v)-width:]
+	}
+	out := make([]byte, width)
+	copy(out[width-len(v):], v)
+	return out
+}
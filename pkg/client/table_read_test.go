@@ -0,0 +1,153 @@
+package client
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+
+	p4_config_v1 "github.com/p4lang/p4runtime/go/p4/config/v1"
+	p4_v1 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+func TestReadTableEntries(t *testing.T) {
+	newEntry := func(mac []byte, actionID uint32, priority int32) *p4_v1.Entity {
+		return &p4_v1.Entity{
+			Entity: &p4_v1.Entity_TableEntry{
+				TableEntry: &p4_v1.TableEntry{
+					TableId: 1,
+					Match: []*p4_v1.FieldMatch{{
+						FieldId:        1,
+						FieldMatchType: &p4_v1.FieldMatch_Exact_{Exact: &p4_v1.FieldMatch_Exact{Value: mac}},
+					}},
+					Action: &p4_v1.TableAction{
+						Type: &p4_v1.TableAction_Action{Action: &p4_v1.Action{ActionId: actionID}},
+					},
+					Priority: priority,
+				},
+			},
+		}
+	}
+	var requests []*p4_v1.ReadRequest
+	p4RtClient := &fakeP4RuntimeClient{
+		readFn: func(ctx context.Context, in *p4_v1.ReadRequest, opts ...grpc.CallOption) (p4_v1.P4Runtime_ReadClient, error) {
+			requests = append(requests, in)
+			done := false
+			return &fakeP4RuntimeReadClient{
+				recvFn: func() (*p4_v1.ReadResponse, error) {
+					if done {
+						return nil, io.EOF
+					}
+					done = true
+					return &p4_v1.ReadResponse{Entities: []*p4_v1.Entity{
+						newEntry([]byte{0x00, 0x11, 0x22, 0x00, 0x00, 0x01}, 10, 0),
+						// canonical bytestring
+						newEntry([]byte{0x11, 0x22, 0x00, 0x00, 0x02}, 11, 0),
+						newEntry([]byte{0x00, 0x11, 0x23, 0x00, 0x00, 0x03}, 10, 0),
+						newEntry([]byte{0xaa, 0x11, 0x22, 0x00, 0x00, 0x04}, 10, 0),
+					}}, nil
+				},
+			}, nil
+		},
+	}
+	p4Info := &p4_config_v1.P4Info{
+		Tables: []*p4_config_v1.Table{{
+			Preamble:          &p4_config_v1.Preamble{Name: "dmac", Id: 1},
+			MatchFields:       []*p4_config_v1.MatchField{{Id: 1, Name: "hdr.ethernet.dstAddr", Bitwidth: 48}},
+			DirectResourceIds: []uint32{20, 21},
+		}, {
+			Preamble: &p4_config_v1.Preamble{Name: "smac", Id: 2},
+		}},
+		DirectCounters: []*p4_config_v1.DirectCounter{
+			{Preamble: &p4_config_v1.Preamble{Name: "dmac_counter", Id: 20}, DirectTableId: 1},
+		},
+		DirectMeters: []*p4_config_v1.DirectMeter{
+			{Preamble: &p4_config_v1.Preamble{Name: "dmac_meter", Id: 21}, DirectTableId: 1},
+		},
+		Actions: []*p4_config_v1.Action{
+			{Preamble: &p4_config_v1.Preamble{Name: "fwd", Id: 10}},
+			{Preamble: &p4_config_v1.Preamble{Name: "drop", Id: 11}},
+		},
+	}
+	fakeClient := newTestClient(p4RtClient, p4Info)
+	lastByte := func(entries []*p4_v1.TableEntry) []byte {
+		var out []byte
+		for _, entry := range entries {
+			value := entry.Match[0].GetExact().Value
+			out = append(out, value[len(value)-1])
+		}
+		return out
+	}
+
+	testCases := []struct {
+		name     string
+		mfs      map[string]MatchInterface
+		options  *TableReadOptions
+		expected []byte
+	}{
+		{"All", nil, nil, []byte{1, 2, 3, 4}},
+		{"Exact", map[string]MatchInterface{
+			"hdr.ethernet.dstAddr": &ExactMatch{Value: []byte{0x11, 0x22, 0x00, 0x00, 0x02}},
+		}, nil, []byte{2}},
+		{"Prefix", map[string]MatchInterface{
+			"hdr.ethernet.dstAddr": &LpmMatch{Value: []byte{0x00, 0x11, 0x22, 0x00, 0x00, 0x00}, PLen: 24},
+		}, nil, []byte{1, 2}},
+		{"Ternary", map[string]MatchInterface{
+			"hdr.ethernet.dstAddr": &TernaryMatch{Value: []byte{0x11, 0, 0, 0, 0}, Mask: []byte{0xff, 0, 0, 0, 0}},
+		}, nil, []byte{1, 2, 3, 4}},
+		{"Range", map[string]MatchInterface{
+			"hdr.ethernet.dstAddr": &RangeMatch{Low: []byte{0x11, 0x22, 0, 0, 0}, High: []byte{0x11, 0x23, 0, 0, 0}},
+		}, nil, []byte{1, 2}},
+		{"Action", nil, &TableReadOptions{Action: "fwd"}, []byte{1, 3, 4}},
+		{"Priority", nil, &TableReadOptions{Priority: 10}, nil},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			entries, err := fakeClient.ReadTableEntries(context.Background(), "dmac", tc.mfs, tc.options)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, lastByte(entries))
+		})
+	}
+
+	t.Run("DefaultEntry", func(t *testing.T) {
+		requests = nil
+		_, err := fakeClient.ReadTableEntries(context.Background(), "dmac", nil, &TableReadOptions{DefaultEntry: true})
+		require.NoError(t, err)
+		require.Len(t, requests, 1)
+		assert.True(t, requests[0].Entities[0].GetTableEntry().IsDefaultAction)
+
+		_, err = fakeClient.ReadTableEntries(context.Background(), "dmac", map[string]MatchInterface{
+			"hdr.ethernet.dstAddr": &ExactMatch{Value: []byte{0x11, 0x22, 0x00, 0x00, 0x02}},
+		}, &TableReadOptions{DefaultEntry: true})
+		assert.EqualError(t, err, "match fields and priority cannot be used to read the default entry of table dmac")
+		_, err = fakeClient.ReadTableEntries(context.Background(), "dmac", nil, &TableReadOptions{DefaultEntry: true, Priority: 10})
+		assert.Error(t, err)
+		assert.Len(t, requests, 1)
+	})
+
+	t.Run("DirectResources", func(t *testing.T) {
+		requests = nil
+		_, err := fakeClient.ReadTableEntries(context.Background(), "dmac", nil, &TableReadOptions{CounterData: true, MeterConfig: true})
+		require.NoError(t, err)
+		require.Len(t, requests, 1)
+		entry := requests[0].Entities[0].GetTableEntry()
+		assert.NotNil(t, entry.CounterData)
+		assert.NotNil(t, entry.MeterConfig)
+
+		_, err = fakeClient.ReadTableEntries(context.Background(), "smac", nil, &TableReadOptions{CounterData: true})
+		assert.EqualError(t, err, "table smac has no direct counter")
+		_, err = fakeClient.ReadTableEntries(context.Background(), "smac", nil, &TableReadOptions{MeterConfig: true})
+		assert.EqualError(t, err, "table smac has no direct meter")
+		assert.Len(t, requests, 1)
+	})
+
+	t.Run("UnknownField", func(t *testing.T) {
+		_, err := fakeClient.ReadTableEntries(context.Background(), "dmac", map[string]MatchInterface{
+			"foo": &ExactMatch{Value: []byte{0x1}},
+		}, nil)
+		assert.EqualError(t, err, "match field foo not found in table dmac")
+	})
+}
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"net"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/golang/protobuf/ptypes/any"
@@ -15,10 +14,6 @@ import (
 	"github.com/RainyBow/p4runtime-go-client/pkg/util/conversion"
 )
 
-const (
-	tableWildcardReadChSize = 100
-)
-
 func ToCanonicalIf(v []byte, cond bool) []byte {
 	if cond {
 		return conversion.ToCanonicalBytestring(v)
@@ -217,7 +212,7 @@ func (s *ActionProfileActionSet) TableAction() *p4_v1.TableAction {
 }
 
 // for default entries: to set use nil for mfs, to unset use nil for mfs and nil
-// for action
+// for action; SetDefaultAction and ResetDefaultAction also check the P4Info
 func (c *Client) NewTableEntry(
 	table string,
 	mfs map[string]MatchInterface,
@@ -248,6 +243,9 @@ func (c *Client) NewTableEntry(
 	return entry
 }
 
+// ReadTableEntry reads the entry of table with the match fields mfs, which
+// must be in the order of the match field IDs of the P4Info. See
+// ReadTableEntries to use the match field names.
 func (c *Client) ReadTableEntry(ctx context.Context, table string, mfs []MatchInterface) (*p4_v1.TableEntry, error) {
 	tableID := c.tableId(table)
 
@@ -278,45 +276,30 @@ func (c *Client) ReadTableEntry(ctx context.Context, table string, mfs []MatchIn
 	return readEntry, nil
 }
 
-func (c *Client) ReadTableEntryWildcard(ctx context.Context, table string) ([]*p4_v1.TableEntry, error) {
-	tableID := c.tableId(table)
-
+// ReadTableEntryIterator returns an iterator over all the entries of table,
+// which does not load them all in memory, unlike ReadTableEntryWildcard.
+func (c *Client) ReadTableEntryIterator(ctx context.Context, table string) *EntityIterator {
 	entry := &p4_v1.TableEntry{
-		TableId: tableID,
+		TableId: c.tableId(table),
 	}
+	return c.ReadEntityIterator(ctx, &p4_v1.Entity{
+		Entity: &p4_v1.Entity_TableEntry{TableEntry: entry},
+	})
+}
 
+func (c *Client) ReadTableEntryWildcard(ctx context.Context, table string) ([]*p4_v1.TableEntry, error) {
 	out := make([]*p4_v1.TableEntry, 0)
-	readEntityCh := make(chan *p4_v1.Entity, tableWildcardReadChSize)
-
-	var wg sync.WaitGroup
-	var err error
-	wg.Add(1)
-
-	go func() {
-		defer wg.Done()
-		for readEntity := range readEntityCh {
-			readEntry := readEntity.GetTableEntry()
-			if readEntry != nil {
-				out = append(out, readEntry)
-			} else if err == nil {
-				// only set the error if this is the first error we encounter
-				// dp not stop reading from the channel, as doing so would cause
-				// ReadEntityWildcard to block indefinitely
-				err = fmt.Errorf("server returned an entity which is not a table entry!")
-			}
+	it := c.ReadTableEntryIterator(ctx, table)
+	defer it.Close()
+	for it.Next() {
+		readEntry := it.Entity().GetTableEntry()
+		if readEntry == nil {
+			return nil, fmt.Errorf("server returned an entity which is not a table entry!")
 		}
-	}()
-
-	if err := c.ReadEntityWildcard(ctx, &p4_v1.Entity{
-		Entity: &p4_v1.Entity_TableEntry{TableEntry: entry},
-	}, readEntityCh); err != nil {
-		// 原样返回err,以便后续可以以GRPC的错误进行处理
-		// return nil, fmt.Errorf("error when reading table entries: %v", err)
-		return nil, err
+		out = append(out, readEntry)
 	}
-
-	wg.Wait()
-	if err != nil {
+	// 原样返回err,以便后续可以以GRPC的错误进行处理
+	if err := it.Err(); err != nil {
 		return nil, err
 	}
 	return out, nil
@@ -355,6 +338,23 @@ func (c *Client) DeleteTableEntry(ctx context.Context, entry *p4_v1.TableEntry)
 	return c.WriteUpdate(ctx, update)
 }
 
+// UpsertTableEntry inserts entry, or modifies it if it already exists.
+func (c *Client) UpsertTableEntry(ctx context.Context, entry *p4_v1.TableEntry) error {
+	err := c.InsertTableEntry(ctx, entry)
+	if IsAlreadyExists(err) {
+		return c.ModifyTableEntry(ctx, entry)
+	}
+	return err
+}
+
+// DeleteTableEntryIfExists deletes entry, and succeeds if it does not exist.
+func (c *Client) DeleteTableEntryIfExists(ctx context.Context, entry *p4_v1.TableEntry) error {
+	if err := c.DeleteTableEntry(ctx, entry); err != nil && !IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
 func formartByts2String(byts []byte) string {
 	switch len(byts) {
 	case 4:
@@ -392,6 +392,11 @@ type TableEntry struct {
 	Fields   []*MatchField `json:"fields"`
 	Action   *Action       `json:"action"`
 	Priority int32         `json:"priority"`
+	// direct counter and meter of the entry, only set when they are read with
+	// the entry, see TableReadOptions. They are not encoded by TableEntryEncode:
+	// writing them with the entry would reset or reconfigure the resources.
+	Counter *Counter `json:"counter,omitempty"`
+	Meter   *Meter   `json:"meter,omitempty"`
 }
 
 func (table_entry *TableEntry) String() string {
@@ -399,7 +404,37 @@ func (table_entry *TableEntry) String() string {
 	for _, field := range table_entry.Fields {
 		field_list = append(field_list, field.String())
 	}
-	return fmt.Sprintf("Table %s: %s => %s", table_entry.Name, strings.Join(field_list, ","), table_entry.Action.String())
+	s := fmt.Sprintf("Table %s: %s => %s", table_entry.Name, strings.Join(field_list, ","), table_entry.Action.String())
+	if table_entry.Counter != nil {
+		s += " " + table_entry.Counter.String()
+	}
+	if table_entry.Meter != nil {
+		s += " " + table_entry.Meter.String()
+	}
+	return s
+}
+
+// Counter is the data of a direct counter.
+type Counter struct {
+	Bytes   int64 `json:"bytes"`
+	Packets int64 `json:"packets"`
+}
+
+func (counter *Counter) String() string {
+	return fmt.Sprintf("counter(bytes=%d,packets=%d)", counter.Bytes, counter.Packets)
+}
+
+// Meter is the configuration of a direct meter, in units of the meter
+// (bytes or packets) per second for the rates, and in units for the bursts.
+type Meter struct {
+	CIR    int64 `json:"cir"`
+	CBurst int64 `json:"cburst"`
+	PIR    int64 `json:"pir"`
+	PBurst int64 `json:"pburst"`
+}
+
+func (meter *Meter) String() string {
+	return fmt.Sprintf("meter(cir=%d,cburst=%d,pir=%d,pburst=%d)", meter.CIR, meter.CBurst, meter.PIR, meter.PBurst)
 }
 
 type MatchType string
@@ -616,6 +651,21 @@ func (c *Client) TableEntryDecode(p4_table_entry *p4_v1.TableEntry) (table_entry
 		}
 		table_entry.Action.Params = append(table_entry.Action.Params, action_param)
 	}
+	// do direct resources
+	if counter := p4_table_entry.CounterData; counter != nil {
+		table_entry.Counter = &Counter{
+			Bytes:   counter.ByteCount,
+			Packets: counter.PacketCount,
+		}
+	}
+	if meter := p4_table_entry.MeterConfig; meter != nil {
+		table_entry.Meter = &Meter{
+			CIR:    meter.Cir,
+			CBurst: meter.Cburst,
+			PIR:    meter.Pir,
+			PBurst: meter.Pburst,
+		}
+	}
 
 	return
 }
@@ -4,6 +4,10 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	p4_config_v1 "github.com/p4lang/p4runtime/go/p4/config/v1"
+	p4_v1 "github.com/p4lang/p4runtime/go/p4/v1"
 )
 
 const mfID = 1
@@ -103,3 +107,36 @@ func TestOptionalMatch(t *testing.T) {
 		assert.Equal(t, tc.out, mf.GetOptional().Value)
 	}
 }
+
+func TestTableEntryDecodeDirectResources(t *testing.T) {
+	p4Info := &p4_config_v1.P4Info{
+		Tables: []*p4_config_v1.Table{{
+			Preamble:    &p4_config_v1.Preamble{Name: "dmac", Id: 1},
+			MatchFields: []*p4_config_v1.MatchField{{Id: 1, Name: "dstAddr", Bitwidth: 48, Match: &p4_config_v1.MatchField_MatchType_{MatchType: p4_config_v1.MatchField_EXACT}}},
+		}},
+		Actions: []*p4_config_v1.Action{{Preamble: &p4_config_v1.Preamble{Name: "drop", Id: 10}}},
+	}
+	c := newTestClient(&fakeP4RuntimeClient{}, p4Info)
+	p4Entry := &p4_v1.TableEntry{
+		TableId: 1,
+		Match: []*p4_v1.FieldMatch{{
+			FieldId:        1,
+			FieldMatchType: &p4_v1.FieldMatch_Exact_{Exact: &p4_v1.FieldMatch_Exact{Value: []byte{0xaa}}},
+		}},
+		Action: &p4_v1.TableAction{
+			Type: &p4_v1.TableAction_Action{Action: &p4_v1.Action{ActionId: 10}},
+		},
+		CounterData: &p4_v1.CounterData{ByteCount: 1500, PacketCount: 1},
+		MeterConfig: &p4_v1.MeterConfig{Cir: 100, Cburst: 10, Pir: 200, Pburst: 20},
+	}
+
+	entry, err := c.TableEntryDecode(p4Entry)
+	require.NoError(t, err)
+	assert.Equal(t, &Counter{Bytes: 1500, Packets: 1}, entry.Counter)
+	assert.Equal(t, &Meter{CIR: 100, CBurst: 10, PIR: 200, PBurst: 20}, entry.Meter)
+
+	encoded, err := c.TableEntryEncode(entry)
+	require.NoError(t, err)
+	assert.Nil(t, encoded.CounterData)
+	assert.Nil(t, encoded.MeterConfig)
+}
@@ -0,0 +1,205 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	//nolint:staticcheck // SA1019 To be resolved later
+	//lint:ignore SA1019 This line added for support golint version of VSC
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc/status"
+
+	p4_v1 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+// Txn stages updates which are applied in order by Commit, one WriteRequest
+// at a time. If one of them fails, Commit undoes the ones which were applied
+// with compensating updates: DELETE for INSERT, and MODIFY or INSERT with the
+// state read before the write for MODIFY and DELETE. Unlike RollbackOnError,
+// this works with every target, but other clients may observe the
+// intermediate states.
+type Txn struct {
+	client      *Client
+	updates     []*p4_v1.Update
+	rollbackCtx context.Context
+}
+
+const (
+	defaultTxnRollbackTimeout = 10 * time.Second
+)
+
+func (c *Client) NewTxn() *Txn {
+	return &Txn{client: c}
+}
+
+func (t *Txn) Insert(entity *p4_v1.Entity) *Txn {
+	return t.Add(&p4_v1.Update{Type: p4_v1.Update_INSERT, Entity: entity})
+}
+
+func (t *Txn) Modify(entity *p4_v1.Entity) *Txn {
+	return t.Add(&p4_v1.Update{Type: p4_v1.Update_MODIFY, Entity: entity})
+}
+
+func (t *Txn) Delete(entity *p4_v1.Entity) *Txn {
+	return t.Add(&p4_v1.Update{Type: p4_v1.Update_DELETE, Entity: entity})
+}
+
+func (t *Txn) Add(update *p4_v1.Update) *Txn {
+	t.updates = append(t.updates, update)
+	return t
+}
+
+// WithRollbackContext sets the context used by Commit for the compensating
+// updates. The context given to Commit is not used for them, as it may be the
+// reason why an update failed. By default, the rollback is bounded by a
+// timeout of 10 seconds.
+func (t *Txn) WithRollbackContext(ctx context.Context) *Txn {
+	t.rollbackCtx = ctx
+	return t
+}
+
+// RollbackFailure is a compensating update which could not be applied.
+type RollbackFailure struct {
+	Update *p4_v1.Update
+	Err    error
+}
+
+// TxnError is returned by Commit when one of the updates fails. It preserves
+// the status code of the error for the failed update.
+type TxnError struct {
+	Index  int // index of the update which failed
+	Update *p4_v1.Update
+	Err    error
+	// RolledBack lists the compensating updates which were applied, in the
+	// order in which they were applied.
+	RolledBack []*p4_v1.Update
+	// RollbackFailures lists the compensating updates which failed; if it is
+	// not empty, the state of the target is not the state before Commit.
+	RollbackFailures []*RollbackFailure
+}
+
+func (e *TxnError) Error() string {
+	msg := fmt.Sprintf("transaction failed at update %d (%v): %v; rolled back %d updates", e.Index, e.Update.GetType(), e.Err, len(e.RolledBack))
+	if len(e.RollbackFailures) > 0 {
+		msg += fmt.Sprintf(", failed to roll back %d updates (first error: %v)", len(e.RollbackFailures), e.RollbackFailures[0].Err)
+	}
+	return msg
+}
+
+func (e *TxnError) Unwrap() error {
+	return e.Err
+}
+
+func (e *TxnError) GRPCStatus() *status.Status {
+	return status.Convert(e.Err)
+}
+
+// Commit applies the staged updates, see Txn. The error is a *TxnError if one
+// of the updates failed, or if the state of an entity could not be read
+// before modifying or deleting it. The compensating updates do not use ctx,
+// see WithRollbackContext.
+func (t *Txn) Commit(ctx context.Context, optionsModifierFns ...func(*WriteOptions)) error {
+	var undo []*p4_v1.Update
+	for idx, update := range t.updates {
+		inverse, err := t.inverse(ctx, update)
+		if err == nil {
+			err = t.client.WriteUpdate(ctx, update, optionsModifierFns...)
+		}
+		if err != nil {
+			txnErr := &TxnError{Index: idx, Update: update, Err: err}
+			rollbackCtx := t.rollbackCtx
+			if rollbackCtx == nil {
+				var cancel context.CancelFunc
+				rollbackCtx, cancel = context.WithTimeout(context.Background(), defaultTxnRollbackTimeout)
+				defer cancel()
+			}
+			t.rollback(rollbackCtx, undo, txnErr, optionsModifierFns...)
+			return txnErr
+		}
+		undo = append(undo, inverse)
+	}
+	return nil
+}
+
+func (t *Txn) rollback(ctx context.Context, undo []*p4_v1.Update, txnErr *TxnError, optionsModifierFns ...func(*WriteOptions)) {
+	for idx := len(undo) - 1; idx >= 0; idx-- {
+		if err := t.client.WriteUpdate(ctx, undo[idx], optionsModifierFns...); err != nil {
+			txnErr.RollbackFailures = append(txnErr.RollbackFailures, &RollbackFailure{Update: undo[idx], Err: err})
+			continue
+		}
+		txnErr.RolledBack = append(txnErr.RolledBack, undo[idx])
+	}
+}
+
+// inverse returns the update which undoes update, reading the current state
+// of the entity if needed.
+func (t *Txn) inverse(ctx context.Context, update *p4_v1.Update) (*p4_v1.Update, error) {
+	switch update.Type {
+	case p4_v1.Update_INSERT:
+		return &p4_v1.Update{Type: p4_v1.Update_DELETE, Entity: update.Entity}, nil
+	case p4_v1.Update_MODIFY, p4_v1.Update_DELETE:
+		snapshot, err := t.client.ReadEntitySingle(ctx, entityKey(update.Entity))
+		if err != nil {
+			// the status code is kept, e.g. NotFound for a DELETE
+			return nil, status.Errorf(status.Code(err), "error when reading entity before %v: %v", update.Type, err)
+		}
+		if update.Type == p4_v1.Update_MODIFY {
+			return &p4_v1.Update{Type: p4_v1.Update_MODIFY, Entity: snapshot}, nil
+		}
+		return &p4_v1.Update{Type: p4_v1.Update_INSERT, Entity: snapshot}, nil
+	default:
+		return nil, fmt.Errorf("unsupported update type %v", update.Type)
+	}
+}
+
+// entityKey returns a copy of entity with only the fields which identify it,
+// to be used in a ReadRequest.
+func entityKey(entity *p4_v1.Entity) *p4_v1.Entity {
+	key := proto.Clone(entity).(*p4_v1.Entity)
+	switch e := key.Entity.(type) {
+	case *p4_v1.Entity_TableEntry:
+		e.TableEntry = &p4_v1.TableEntry{
+			TableId:         e.TableEntry.TableId,
+			Match:           e.TableEntry.Match,
+			Priority:        e.TableEntry.Priority,
+			IsDefaultAction: e.TableEntry.IsDefaultAction,
+		}
+	case *p4_v1.Entity_ActionProfileMember:
+		e.ActionProfileMember = &p4_v1.ActionProfileMember{
+			ActionProfileId: e.ActionProfileMember.ActionProfileId,
+			MemberId:        e.ActionProfileMember.MemberId,
+		}
+	case *p4_v1.Entity_ActionProfileGroup:
+		e.ActionProfileGroup = &p4_v1.ActionProfileGroup{
+			ActionProfileId: e.ActionProfileGroup.ActionProfileId,
+			GroupId:         e.ActionProfileGroup.GroupId,
+		}
+	case *p4_v1.Entity_CounterEntry:
+		e.CounterEntry.Data = nil
+	case *p4_v1.Entity_DirectCounterEntry:
+		e.DirectCounterEntry.Data = nil
+	case *p4_v1.Entity_MeterEntry:
+		e.MeterEntry.Config = nil
+		e.MeterEntry.CounterData = nil
+	case *p4_v1.Entity_DirectMeterEntry:
+		e.DirectMeterEntry.Config = nil
+		e.DirectMeterEntry.CounterData = nil
+	case *p4_v1.Entity_RegisterEntry:
+		e.RegisterEntry.Data = nil
+	case *p4_v1.Entity_DigestEntry:
+		e.DigestEntry.Config = nil
+	case *p4_v1.Entity_PacketReplicationEngineEntry:
+		switch pre := e.PacketReplicationEngineEntry.Type.(type) {
+		case *p4_v1.PacketReplicationEngineEntry_MulticastGroupEntry:
+			pre.MulticastGroupEntry = &p4_v1.MulticastGroupEntry{
+				MulticastGroupId: pre.MulticastGroupEntry.MulticastGroupId,
+			}
+		case *p4_v1.PacketReplicationEngineEntry_CloneSessionEntry:
+			pre.CloneSessionEntry = &p4_v1.CloneSessionEntry{
+				SessionId: pre.CloneSessionEntry.SessionId,
+			}
+		}
+	}
+	return key
+}
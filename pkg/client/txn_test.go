@@ -0,0 +1,136 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	p4_v1 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+// TestTxnRollback ensures that when an update of a Txn fails, the updates which were applied are
+// undone in reverse order, using the state read before each MODIFY.
+func TestTxnRollback(t *testing.T) {
+	newGroup := func(mgid uint32, ports ...uint32) *p4_v1.Entity {
+		replicas := make([]*p4_v1.Replica, 0, len(ports))
+		for _, port := range ports {
+			replicas = append(replicas, &p4_v1.Replica{EgressPort: port})
+		}
+		return &p4_v1.Entity{Entity: &p4_v1.Entity_PacketReplicationEngineEntry{
+			PacketReplicationEngineEntry: &p4_v1.PacketReplicationEngineEntry{
+				Type: &p4_v1.PacketReplicationEngineEntry_MulticastGroupEntry{
+					MulticastGroupEntry: &p4_v1.MulticastGroupEntry{MulticastGroupId: mgid, Replicas: replicas},
+				},
+			},
+		}}
+	}
+	mgid := func(update *p4_v1.Update) uint32 {
+		return update.GetEntity().GetPacketReplicationEngineEntry().GetMulticastGroupEntry().GetMulticastGroupId()
+	}
+
+	var writes []*p4_v1.Update
+	p4RtClient := &fakeP4RuntimeClient{
+		readFn: func(ctx context.Context, in *p4_v1.ReadRequest, opts ...grpc.CallOption) (p4_v1.P4Runtime_ReadClient, error) {
+			key := in.Entities[0].GetPacketReplicationEngineEntry().GetMulticastGroupEntry()
+			assert.Empty(t, key.Replicas, "only the key should be read")
+			done := false
+			return &fakeP4RuntimeReadClient{
+				recvFn: func() (*p4_v1.ReadResponse, error) {
+					if done {
+						return nil, io.EOF
+					}
+					done = true
+					return &p4_v1.ReadResponse{Entities: []*p4_v1.Entity{newGroup(key.MulticastGroupId, 1)}}, nil
+				},
+			}, nil
+		},
+		writeFn: func(ctx context.Context, in *p4_v1.WriteRequest, opts ...grpc.CallOption) (*p4_v1.WriteResponse, error) {
+			writes = append(writes, in.Updates...)
+			if in.Updates[0].Type == p4_v1.Update_DELETE && mgid(in.Updates[0]) == 3 {
+				return nil, status.Error(codes.NotFound, "no such group")
+			}
+			return &p4_v1.WriteResponse{}, nil
+		},
+	}
+	fakeClient := newTestClient(p4RtClient, nil)
+
+	err := fakeClient.NewTxn().
+		Insert(newGroup(1, 1, 2)).
+		Modify(newGroup(2, 2, 3)).
+		Delete(newGroup(3)).
+		Commit(context.Background())
+	var txnErr *TxnError
+	require.True(t, errors.As(err, &txnErr))
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, 2, txnErr.Index)
+	assert.Empty(t, txnErr.RollbackFailures)
+	require.Len(t, txnErr.RolledBack, 2)
+	assert.Equal(t, p4_v1.Update_MODIFY, txnErr.RolledBack[0].Type)
+	assert.Equal(t, uint32(2), mgid(txnErr.RolledBack[0]))
+	assert.Len(t, txnErr.RolledBack[0].GetEntity().GetPacketReplicationEngineEntry().GetMulticastGroupEntry().Replicas, 1)
+	assert.Equal(t, p4_v1.Update_DELETE, txnErr.RolledBack[1].Type)
+	assert.Equal(t, uint32(1), mgid(txnErr.RolledBack[1]))
+	assert.Len(t, writes, 5)
+}
+
+// TestTxnRollbackContext ensures that the compensating updates are applied when the context of
+// Commit is done, and that errors when reading the state of an entity keep their status code.
+func TestTxnRollbackContext(t *testing.T) {
+	newGroup := func(mgid uint32) *p4_v1.Entity {
+		return &p4_v1.Entity{Entity: &p4_v1.Entity_PacketReplicationEngineEntry{
+			PacketReplicationEngineEntry: &p4_v1.PacketReplicationEngineEntry{
+				Type: &p4_v1.PacketReplicationEngineEntry_MulticastGroupEntry{
+					MulticastGroupEntry: &p4_v1.MulticastGroupEntry{MulticastGroupId: mgid},
+				},
+			},
+		}}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var rollbackCtx context.Context
+	p4RtClient := &fakeP4RuntimeClient{
+		readFn: func(ctx context.Context, in *p4_v1.ReadRequest, opts ...grpc.CallOption) (p4_v1.P4Runtime_ReadClient, error) {
+			return nil, status.Error(codes.NotFound, "no such group")
+		},
+		writeFn: func(writeCtx context.Context, in *p4_v1.WriteRequest, opts ...grpc.CallOption) (*p4_v1.WriteResponse, error) {
+			if in.Updates[0].Type == p4_v1.Update_DELETE {
+				rollbackCtx = writeCtx
+				return &p4_v1.WriteResponse{}, writeCtx.Err()
+			}
+			if in.Updates[0].GetEntity().GetPacketReplicationEngineEntry().GetMulticastGroupEntry().GetMulticastGroupId() == 2 {
+				// e.g. the caller gives up while the write is in progress
+				cancel()
+				return nil, status.FromContextError(ctx.Err()).Err()
+			}
+			return &p4_v1.WriteResponse{}, nil
+		},
+	}
+	fakeClient := newTestClient(p4RtClient, nil)
+
+	err := fakeClient.NewTxn().Insert(newGroup(1)).Insert(newGroup(2)).Commit(ctx)
+	var txnErr *TxnError
+	require.True(t, errors.As(err, &txnErr))
+	assert.Equal(t, codes.Canceled, status.Code(err))
+	assert.Empty(t, txnErr.RollbackFailures)
+	assert.Len(t, txnErr.RolledBack, 1)
+	_, hasDeadline := rollbackCtx.Deadline()
+	assert.True(t, hasDeadline, "the default rollback context has a timeout")
+
+	type ctxKey struct{}
+	customCtx := context.WithValue(context.Background(), ctxKey{}, true)
+	err = fakeClient.NewTxn().
+		Insert(newGroup(1)).
+		Delete(newGroup(3)).
+		WithRollbackContext(customCtx).
+		Commit(context.Background())
+	require.True(t, errors.As(err, &txnErr))
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, true, rollbackCtx.Value(ctxKey{}))
+}
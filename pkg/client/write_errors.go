@@ -0,0 +1,199 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	p4_v1 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+// UpdateError is the outcome of one of the updates of a failed WriteRequest,
+// as reported by the server. The update may have succeeded, see Failed.
+type UpdateError struct {
+	Index  int // index of the update in the WriteRequest
+	Update *p4_v1.Update
+	// EntityType is the name of the entity message, e.g. "TableEntry".
+	EntityType string
+	// TableName is the P4Info name of the table for a TableEntry.
+	TableName string
+	Err       *p4_v1.Error
+}
+
+func (e *UpdateError) Code() codes.Code {
+	return codes.Code(e.Err.GetCanonicalCode())
+}
+
+// Failed returns true if the update was not applied.
+func (e *UpdateError) Failed() bool {
+	return e.Code() != codes.OK
+}
+
+func (e *UpdateError) Error() string {
+	target := e.EntityType
+	if e.TableName != "" {
+		target = fmt.Sprintf("%s in %s", e.EntityType, e.TableName)
+	}
+	msg := fmt.Sprintf("update %d (%v %s): %v", e.Index, e.Update.GetType(), target, e.Code())
+	if e.Err.GetMessage() != "" {
+		msg += ": " + e.Err.GetMessage()
+	}
+	return msg
+}
+
+func (e *UpdateError) GRPCStatus() *status.Status {
+	return status.New(e.Code(), e.Error())
+}
+
+// WriteError is returned by Write operations when the server reports the
+// outcome of each update in the details of the gRPC status, as required by the
+// P4Runtime spec for batched writes. It preserves the status code of the RPC.
+type WriteError struct {
+	Status *status.Status
+	// Updates has one entry for each update of the WriteRequest, in order.
+	Updates []*UpdateError
+	// Cause is the error of a WriteRequest which failed as a whole, e.g.
+	// ErrNotPrimary, when the write was split in several requests.
+	Cause error
+}
+
+// Failed returns the updates which were not applied.
+func (e *WriteError) Failed() []*UpdateError {
+	var failed []*UpdateError
+	for _, u := range e.Updates {
+		if u.Failed() {
+			failed = append(failed, u)
+		}
+	}
+	return failed
+}
+
+// Succeeded returns the updates which were applied.
+func (e *WriteError) Succeeded() []*UpdateError {
+	var succeeded []*UpdateError
+	for _, u := range e.Updates {
+		if !u.Failed() {
+			succeeded = append(succeeded, u)
+		}
+	}
+	return succeeded
+}
+
+func (e *WriteError) Error() string {
+	failed := e.Failed()
+	msgs := make([]string, 0, len(failed))
+	for _, u := range failed {
+		msgs = append(msgs, u.Error())
+	}
+	return fmt.Sprintf("write failed for %d out of %d updates: %s", len(failed), len(e.Updates), strings.Join(msgs, "; "))
+}
+
+func (e *WriteError) GRPCStatus() *status.Status {
+	return e.Status
+}
+
+func (e *WriteError) Unwrap() error {
+	return e.Cause
+}
+
+// newWriteError converts the error returned by the Write RPC to a *WriteError,
+// if the status details have the per-update p4_v1.Errors. Otherwise err is
+// returned unchanged.
+func (c *Client) newWriteError(err error, updates []*p4_v1.Update) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	details := st.Proto().GetDetails()
+	if len(details) == 0 || len(details) != len(updates) {
+		return err
+	}
+	writeErr := &WriteError{
+		Status:  st,
+		Updates: make([]*UpdateError, 0, len(updates)),
+	}
+	for idx, detail := range details {
+		p4Err := &p4_v1.Error{}
+		if detail.UnmarshalTo(p4Err) != nil {
+			return err
+		}
+		writeErr.Updates = append(writeErr.Updates, c.newUpdateError(idx, updates[idx], p4Err))
+	}
+	return writeErr
+}
+
+func (c *Client) newUpdateError(idx int, update *p4_v1.Update, p4Err *p4_v1.Error) *UpdateError {
+	updateErr := &UpdateError{
+		Index:      idx,
+		Update:     update,
+		EntityType: entityTypeName(update.GetEntity()),
+		Err:        p4Err,
+	}
+	if entry := update.GetEntity().GetTableEntry(); entry != nil {
+		updateErr.TableName = c.findTableById(entry.TableId).GetPreamble().GetName()
+	}
+	return updateErr
+}
+
+// entityTypeName returns the name of the message set in the entity oneof.
+func entityTypeName(entity *p4_v1.Entity) string {
+	m := entity.ProtoReflect()
+	field := m.WhichOneof(m.Descriptor().Oneofs().ByName("entity"))
+	if field == nil {
+		return ""
+	}
+	return string(field.Message().Name())
+}
+
+// errorCode returns the canonical code for err. For a *WriteError, it is the
+// code shared by all the updates which failed, if any.
+func errorCode(err error) codes.Code {
+	if err == nil {
+		return codes.OK
+	}
+	var writeErr *WriteError
+	if errors.As(err, &writeErr) {
+		failed := writeErr.Failed()
+		if len(failed) == 0 {
+			return writeErr.Status.Code()
+		}
+		for _, u := range failed[1:] {
+			if u.Code() != failed[0].Code() {
+				return codes.Unknown
+			}
+		}
+		return failed[0].Code()
+	}
+	var grpcErr interface{ GRPCStatus() *status.Status }
+	if errors.As(err, &grpcErr) {
+		return grpcErr.GRPCStatus().Code()
+	}
+	return status.Code(err)
+}
+
+// IsAlreadyExists returns true if err, or all the failed updates of a
+// *WriteError, have the ALREADY_EXISTS code.
+func IsAlreadyExists(err error) bool {
+	return errorCode(err) == codes.AlreadyExists
+}
+
+// IsNotFound returns true if err, or all the failed updates of a *WriteError,
+// have the NOT_FOUND code.
+func IsNotFound(err error) bool {
+	return errorCode(err) == codes.NotFound
+}
+
+// IsPermissionDenied returns true if err, or all the failed updates of a
+// *WriteError, have the PERMISSION_DENIED code.
+func IsPermissionDenied(err error) bool {
+	return errorCode(err) == codes.PermissionDenied
+}
+
+// IsResourceExhausted returns true if err, or all the failed updates of a
+// *WriteError, have the RESOURCE_EXHAUSTED code.
+func IsResourceExhausted(err error) bool {
+	return errorCode(err) == codes.ResourceExhausted
+}
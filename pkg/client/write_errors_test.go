@@ -0,0 +1,92 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	p4_config_v1 "github.com/p4lang/p4runtime/go/p4/config/v1"
+	p4_v1 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+func TestWriteError(t *testing.T) {
+	p4RtClient := &fakeP4RuntimeClient{
+		writeFn: func(ctx context.Context, in *p4_v1.WriteRequest, opts ...grpc.CallOption) (*p4_v1.WriteResponse, error) {
+			st, err := status.New(codes.Unknown, "batch failed").WithDetails(
+				&p4_v1.Error{CanonicalCode: int32(codes.OK)},
+				&p4_v1.Error{CanonicalCode: int32(codes.AlreadyExists), Message: "entry exists"},
+				&p4_v1.Error{CanonicalCode: int32(codes.OK)},
+			)
+			require.NoError(t, err)
+			return nil, st.Err()
+		},
+	}
+	p4Info := &p4_config_v1.P4Info{
+		Tables: []*p4_config_v1.Table{
+			{Preamble: &p4_config_v1.Preamble{Name: "IngressImpl.dmac", Id: 10}},
+		},
+	}
+	fakeClient := newTestClient(p4RtClient, p4Info)
+	updates := []*p4_v1.Update{
+		{Type: p4_v1.Update_INSERT, Entity: &p4_v1.Entity{Entity: &p4_v1.Entity_TableEntry{TableEntry: &p4_v1.TableEntry{TableId: 10}}}},
+		{Type: p4_v1.Update_INSERT, Entity: &p4_v1.Entity{Entity: &p4_v1.Entity_TableEntry{TableEntry: &p4_v1.TableEntry{TableId: 10}}}},
+		{Type: p4_v1.Update_MODIFY, Entity: &p4_v1.Entity{Entity: &p4_v1.Entity_CounterEntry{CounterEntry: &p4_v1.CounterEntry{}}}},
+	}
+
+	err := fakeClient.WriteManyUpdate(context.Background(), updates)
+	var writeErr *WriteError
+	require.True(t, errors.As(err, &writeErr))
+	assert.Equal(t, codes.Unknown, status.Code(err))
+	require.Len(t, writeErr.Updates, 3)
+	assert.Len(t, writeErr.Succeeded(), 2)
+	failed := writeErr.Failed()
+	require.Len(t, failed, 1)
+	assert.Equal(t, 1, failed[0].Index)
+	assert.Same(t, updates[1], failed[0].Update)
+	assert.Equal(t, "TableEntry", failed[0].EntityType)
+	assert.Equal(t, "IngressImpl.dmac", failed[0].TableName)
+	assert.Equal(t, codes.AlreadyExists, status.Code(failed[0]))
+	assert.Equal(t, "update 1 (INSERT TableEntry in IngressImpl.dmac): AlreadyExists: entry exists", failed[0].Error())
+	assert.Equal(t, "CounterEntry", writeErr.Updates[2].EntityType)
+}
+
+func TestUpsertTableEntry(t *testing.T) {
+	var updateTypes []p4_v1.Update_Type
+	p4RtClient := &fakeP4RuntimeClient{
+		writeFn: func(ctx context.Context, in *p4_v1.WriteRequest, opts ...grpc.CallOption) (*p4_v1.WriteResponse, error) {
+			updateTypes = append(updateTypes, in.Updates[0].Type)
+			switch in.Updates[0].Type {
+			case p4_v1.Update_INSERT:
+				// the status code of the RPC does not matter, only the per-update code
+				st, err := status.New(codes.Unknown, "write failed").WithDetails(
+					&p4_v1.Error{CanonicalCode: int32(codes.AlreadyExists)},
+				)
+				require.NoError(t, err)
+				return nil, st.Err()
+			case p4_v1.Update_DELETE:
+				return nil, status.Error(codes.NotFound, "no such entry")
+			}
+			return &p4_v1.WriteResponse{}, nil
+		},
+	}
+	fakeClient := newTestClient(p4RtClient, nil)
+	ctx := context.Background()
+
+	err := fakeClient.InsertTableEntry(ctx, &p4_v1.TableEntry{})
+	assert.True(t, IsAlreadyExists(err))
+	assert.False(t, IsNotFound(err))
+	assert.True(t, IsAlreadyExists(&TxnError{Err: err}))
+	assert.True(t, IsPermissionDenied(ErrNotPrimary))
+	assert.True(t, IsResourceExhausted(ErrSendQueueFull))
+
+	updateTypes = nil
+	assert.NoError(t, fakeClient.UpsertTableEntry(ctx, &p4_v1.TableEntry{}))
+	assert.Equal(t, []p4_v1.Update_Type{p4_v1.Update_INSERT, p4_v1.Update_MODIFY}, updateTypes)
+	assert.NoError(t, fakeClient.DeleteTableEntryIfExists(ctx, &p4_v1.TableEntry{}))
+}
@@ -0,0 +1,79 @@
+package client
+
+import (
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	p4_v1 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+type Atomicity int32
+
+const (
+	// ContinueOnError is the default: the server applies as many updates as
+	// possible and reports an error for each update which failed.
+	ContinueOnError = Atomicity(p4_v1.WriteRequest_CONTINUE_ON_ERROR)
+	// RollbackOnError makes the server undo all the updates if any of them
+	// fails. The dataplane may observe intermediate states.
+	RollbackOnError = Atomicity(p4_v1.WriteRequest_ROLLBACK_ON_ERROR)
+	// DataplaneAtomic is like RollbackOnError, but the dataplane only observes
+	// the state before or after the whole batch.
+	DataplaneAtomic = Atomicity(p4_v1.WriteRequest_DATAPLANE_ATOMIC)
+)
+
+func (a Atomicity) String() string {
+	return p4_v1.WriteRequest_Atomicity(a).String()
+}
+
+type WriteOptions struct {
+	Atomicity Atomicity
+}
+
+func WithAtomicity(atomicity Atomicity) func(*WriteOptions) {
+	return func(options *WriteOptions) {
+		options.Atomicity = atomicity
+	}
+}
+
+func (c *Client) writeOptions(optionsModifierFns ...func(*WriteOptions)) WriteOptions {
+	options := WriteOptions{
+		Atomicity: ContinueOnError,
+	}
+	for _, fn := range optionsModifierFns {
+		fn(&options)
+	}
+	return options
+}
+
+// UnsupportedAtomicityError is returned by writes which use an atomicity mode
+// other than ContinueOnError when the server returns UNIMPLEMENTED, which is
+// how the P4Runtime spec makes servers reject an unsupported mode. The
+// Capabilities RPC cannot be used to check the mode beforehand: its response
+// only has the p4runtime_api_version. UNIMPLEMENTED may also be returned for
+// other reasons, e.g. an entity which the target does not support, so Err
+// keeps the error returned by the server.
+type UnsupportedAtomicityError struct {
+	Atomicity Atomicity
+	Err       error
+}
+
+func (e *UnsupportedAtomicityError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("atomicity %v is not supported by the server", e.Atomicity)
+	}
+	return fmt.Sprintf("atomicity %v is not supported by the server: %v", e.Atomicity, e.Err)
+}
+
+func (e *UnsupportedAtomicityError) Unwrap() error {
+	return e.Err
+}
+
+func (e *UnsupportedAtomicityError) GRPCStatus() *status.Status {
+	return status.New(codes.Unimplemented, e.Error())
+}
+
+func isUnsupportedAtomicity(atomicity Atomicity, err error) bool {
+	return atomicity != ContinueOnError && status.Code(err) == codes.Unimplemented
+}
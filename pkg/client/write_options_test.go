@@ -0,0 +1,59 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	p4_v1 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+func TestUnsupportedAtomicity(t *testing.T) {
+	var requests []*p4_v1.WriteRequest
+	p4RtClient := &fakeP4RuntimeClient{
+		writeFn: func(ctx context.Context, in *p4_v1.WriteRequest, opts ...grpc.CallOption) (*p4_v1.WriteResponse, error) {
+			requests = append(requests, in)
+			if in.Atomicity == p4_v1.WriteRequest_DATAPLANE_ATOMIC {
+				return nil, status.Error(codes.Unimplemented, "DATAPLANE_ATOMIC is not supported")
+			}
+			if len(in.Updates[0].GetEntity().GetTableEntry().GetMatch()) > 0 {
+				return nil, status.Error(codes.Unimplemented, "match kind not supported")
+			}
+			return &p4_v1.WriteResponse{}, nil
+		},
+	}
+	fakeClient := newTestClient(p4RtClient, nil)
+	fakeClient.RoleName = "routing"
+	ctx := context.Background()
+
+	assert.NoError(t, fakeClient.WriteUpdate(ctx, &p4_v1.Update{}, WithAtomicity(RollbackOnError)))
+	for i := 0; i < 2; i++ {
+		err := fakeClient.WriteUpdate(ctx, &p4_v1.Update{}, WithAtomicity(DataplaneAtomic))
+		var atomicityErr *UnsupportedAtomicityError
+		require.True(t, errors.As(err, &atomicityErr))
+		assert.Equal(t, DataplaneAtomic, atomicityErr.Atomicity)
+		assert.Equal(t, codes.Unimplemented, status.Code(err))
+		assert.Equal(t, "DATAPLANE_ATOMIC is not supported", status.Convert(atomicityErr.Err).Message())
+	}
+	// the mode is not remembered, every write is sent to the server
+	require.Len(t, requests, 3)
+	assert.Equal(t, p4_v1.WriteRequest_ROLLBACK_ON_ERROR, requests[0].Atomicity)
+	for _, req := range requests {
+		assert.Equal(t, "routing", req.Role)
+	}
+
+	// UNIMPLEMENTED with the default atomicity is not about atomicity
+	unsupportedMatch := &p4_v1.Update{Entity: &p4_v1.Entity{Entity: &p4_v1.Entity_TableEntry{
+		TableEntry: &p4_v1.TableEntry{Match: []*p4_v1.FieldMatch{{FieldId: 1}}},
+	}}}
+	err := fakeClient.WriteUpdate(ctx, unsupportedMatch)
+	var atomicityErr *UnsupportedAtomicityError
+	assert.False(t, errors.As(err, &atomicityErr))
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+}